@@ -1,3 +1,3 @@
 module github.com/FrankLeeC/Aurora
 
-go 1.12
+go 1.24
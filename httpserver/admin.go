@@ -0,0 +1,201 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const checkTimeout = 5 * time.Second
+
+type check struct {
+	name string
+	f    func() error
+}
+
+// RouteInfo a registered route and filters applied to it
+type RouteInfo struct {
+	Host    string   `json:"host,omitempty"` // pattern of virtual host, empty for routes of server
+	Pattern string   `json:"pattern"`
+	Kind    string   `json:"kind"` // static, dynamic or prefix
+	Filters []string `json:"filters"`
+}
+
+// AddCheck register a readiness check, /readyz of admin server fails if any check returns an error
+// e.g.
+//     s.AddCheck("orm", orm.Ping)
+//     s.AddCheck("jobs", func() error {
+//         if jq.Backlog() > 1000 {
+//             return errors.New("too many jobs waiting")
+//         }
+//         return nil
+//     })
+func (a *HTTPServer) AddCheck(name string, f func() error) {
+	a.checks = append(a.checks, &check{name: name, f: f})
+}
+
+// Admin launch an admin serve on 127.0.0.1:port, it does not block
+//     /healthz        200 while process is alive
+//     /readyz         200 if server is not shutting down and all checks pass, otherwise 503
+//     /debug/pprof/   runtime profiles, same as net/http/pprof
+//     /debug/routes   registered routes and their filters
+// admin serve is closed after server has been shutdown
+func (a *HTTPServer) Admin(port int) {
+	a.AdminAddr("127.0.0.1:" + strconv.Itoa(port))
+}
+
+// AdminAddr launch an admin serve on addr, see Admin
+// profiles and routes should not be public, 127.0.0.1 is used if host of addr is empty,
+// use 0.0.0.0:port explicitly to bind all interfaces
+func (a *HTTPServer) AdminAddr(addr string) {
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", a.readyz)
+	mux.HandleFunc("/debug/routes", func(rw http.ResponseWriter, r *http.Request) {
+		rs := a.defaultHandler.routes()
+		for _, vh := range a.defaultHandler.hosts {
+			for _, r := range vh.h.routes() {
+				r.Host = vh.pattern
+				rs = append(rs, r)
+			}
+		}
+		writeJSON(rw, http.StatusOK, rs)
+	})
+	// importing net/http/pprof also registers these handlers on http.DefaultServeMux,
+	// httpserver never serves DefaultServeMux, so profiles stay on admin serve
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	a.admin = &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := a.admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			a.defaultHandler.logf("admin ListenAndServe error: %s", err.Error())
+		}
+	}()
+}
+
+func (a *HTTPServer) readyz(rw http.ResponseWriter, r *http.Request) {
+	result := make(map[string]string, len(a.checks))
+	mutex := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	for _, c := range a.checks {
+		wg.Add(1)
+		go func(c *check) {
+			defer wg.Done()
+			s := "ok"
+			if err := runCheck(c.f); err != nil {
+				s = err.Error()
+			}
+			mutex.Lock()
+			result[c.name] = s
+			mutex.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	status, code := "ok", http.StatusOK
+	for _, s := range result {
+		if s != "ok" {
+			status, code = "fail", http.StatusServiceUnavailable
+		}
+	}
+	if !a.Ready() {
+		status, code = "draining", http.StatusServiceUnavailable
+	}
+	writeJSON(rw, code, map[string]interface{}{"status": status, "checks": result})
+}
+
+func runCheck(f func() error) (err error) {
+	c := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				c <- fmt.Errorf("panic: %v", v)
+			}
+		}()
+		c <- f()
+	}()
+	select {
+	case err = <-c:
+		return err
+	case <-time.After(checkTimeout):
+		return fmt.Errorf("timeout after %v", checkTimeout)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, code int, v interface{}) {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(code)
+	rw.Write(b)
+}
+
+// routes list registered routes and filters which will be applied to them
+func (a *handler) routes() []*RouteInfo {
+	rs := make([]*RouteInfo, 0)
+	for p := range a.plainHandlers {
+		if _, c := a.regexHandlers[p]; !c {
+			rs = append(rs, &RouteInfo{Pattern: p, Kind: "static"})
+		}
+	}
+	for p := range a.regexHandlers {
+		kind := "dynamic"
+		if !strings.Contains(p, "{") {
+			kind = "static"
+		}
+		rs = append(rs, &RouteInfo{Pattern: p, Kind: kind})
+	}
+	for p := range a.prefixHandlers {
+		rs = append(rs, &RouteInfo{Pattern: strings.TrimRight(p, "/") + "/*", Kind: "prefix"})
+	}
+	for _, r := range rs {
+		p := r.Pattern
+		if r.Kind == "prefix" {
+			p = strings.TrimSuffix(p, "*")
+		}
+		r.Filters = make([]string, 0)
+		for _, e := range a.matchFilters(p) {
+			r.Filters = append(r.Filters, fmt.Sprintf("%T", e.f))
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Pattern < rs[j].Pattern
+	})
+	return rs
+}
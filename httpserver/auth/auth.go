@@ -0,0 +1,113 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package auth authentication filters for httpserver
+//
+// every filter puts the verified *Principal into request, get it by auth.Get(req).
+// requests without credentials or with invalid credentials are refused with 401,
+// requests refused by Authorize are refused with 403
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+const requestKey = "auth.principal"
+
+// Principal authenticated identity
+type Principal struct {
+	Name   string
+	Scheme string                 // Basic, Bearer or HMAC
+	Claims map[string]interface{} // claims of jwt, nil for other schemes
+}
+
+// Get get principal verified by filters of this package, nil if request is not authenticated
+func Get(req *httpserver.Request) *Principal {
+	if p, ok := req.Get(requestKey).(*Principal); ok {
+		return p
+	}
+	return nil
+}
+
+func unauthorized(rsp *httpserver.Response, challenge string) bool {
+	if challenge != "" {
+		rsp.Header().Set("WWW-Authenticate", challenge)
+	}
+	rsp.WriteStatusCode(http.StatusUnauthorized)
+	return false
+}
+
+func forbidden(rsp *httpserver.Response) bool {
+	rsp.WriteStatusCode(http.StatusForbidden)
+	return false
+}
+
+func tooLarge(rsp *httpserver.Response) bool {
+	rsp.WriteStatusCode(http.StatusRequestEntityTooLarge)
+	return false
+}
+
+// accept store principal, check it with authorize
+func accept(rsp *httpserver.Response, r *httpserver.Request, p *Principal, authorize func(p *Principal, r *httpserver.Request) bool) bool {
+	if authorize != nil && !authorize(p, r) {
+		return forbidden(rsp)
+	}
+	r.Set(requestKey, p)
+	return true
+}
+
+// Basic http basic authentication
+type Basic struct {
+	realm     string
+	verify    func(user, password string) bool
+	Authorize func(p *Principal, r *httpserver.Request) bool // optional
+}
+
+// NewBasic return a basic authentication filter
+// verify checks user and password, use SecureCompare to compare passwords
+func NewBasic(realm string, verify func(user, password string) bool) *Basic {
+	return &Basic{realm: realm, verify: verify}
+}
+
+// Before verify credentials
+func (a *Basic) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	challenge := `Basic realm="` + a.realm + `", charset="UTF-8"`
+	user, password, ok := r.BasicAuth()
+	if !ok || !a.verify(user, password) {
+		return unauthorized(rsp, challenge)
+	}
+	return accept(rsp, r, &Principal{Name: user, Scheme: "Basic"}, a.Authorize)
+}
+
+// After do nothing
+func (a *Basic) After(rsp *httpserver.Response, r *httpserver.Request) {
+}
+
+// SecureCompare compare strings in constant time
+func SecureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,168 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+// headers of signed requests
+const (
+	HeaderKey       = "X-Auth-Key"
+	HeaderTimestamp = "X-Auth-Timestamp"
+	HeaderNonce     = "X-Auth-Nonce"
+	HeaderSignature = "X-Auth-Signature"
+)
+
+// HMAC hmac-sha256 signed requests
+//
+// signature is base64 of hmac-sha256 over
+// method \n request uri \n timestamp \n nonce \n hex sha256 of body
+//
+// timestamp is unix seconds and must be within Window of server time,
+// a nonce can be used only once in Window.
+// body is read into memory to be verified, requests with body larger than MaxBodySize are refused with 413
+type HMAC struct {
+	secret      func(key string) ([]byte, bool)
+	Window      time.Duration                                  // default 5 minutes
+	MaxBodySize int64                                          // default 1MB
+	Authorize   func(p *Principal, r *httpserver.Request) bool // optional
+	mutex       *sync.Mutex
+	nonces      map[string]time.Time
+	sweep       time.Time
+}
+
+// NewHMAC return a hmac filter, secret returns secret of a key id
+func NewHMAC(secret func(key string) ([]byte, bool)) *HMAC {
+	return &HMAC{
+		secret:      secret,
+		Window:      5 * time.Minute,
+		MaxBodySize: 1 << 20,
+		mutex:       new(sync.Mutex),
+		nonces:      make(map[string]time.Time),
+	}
+}
+
+// Before verify signature
+func (a *HMAC) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	key := r.Header.Get(HeaderKey)
+	ts := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	sig, err := base64.StdEncoding.DecodeString(r.Header.Get(HeaderSignature))
+	if key == "" || ts == "" || nonce == "" || err != nil || len(sig) == 0 {
+		return unauthorized(rsp, "HMAC")
+	}
+	secret, ok := a.secret(key)
+	if !ok {
+		return unauthorized(rsp, "HMAC")
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return unauthorized(rsp, "HMAC")
+	}
+	now := time.Now()
+	t := time.Unix(sec, 0)
+	if t.Before(now.Add(-a.Window)) || t.After(now.Add(a.Window)) {
+		return unauthorized(rsp, "HMAC")
+	}
+	var body []byte
+	if r.Body != nil {
+		if r.ContentLength > a.MaxBodySize {
+			return tooLarge(rsp)
+		}
+		if body, err = ioutil.ReadAll(io.LimitReader(r.Body, a.MaxBodySize+1)); err != nil {
+			return unauthorized(rsp, "HMAC")
+		}
+		r.Body.Close()
+		if int64(len(body)) > a.MaxBodySize {
+			return tooLarge(rsp)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if !hmac.Equal(sig, Sign(secret, r.Request, ts, nonce, body)) {
+		return unauthorized(rsp, "HMAC")
+	}
+	if !a.useNonce(key+":"+nonce, now) {
+		return unauthorized(rsp, "HMAC")
+	}
+	return accept(rsp, r, &Principal{Name: key, Scheme: "HMAC"}, a.Authorize)
+}
+
+// After do nothing
+func (a *HMAC) After(rsp *httpserver.Response, r *httpserver.Request) {
+}
+
+// useNonce return false if nonce has been used in window
+func (a *HMAC) useNonce(nonce string, now time.Time) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if now.After(a.sweep) {
+		for n, exp := range a.nonces {
+			if now.After(exp) {
+				delete(a.nonces, n)
+			}
+		}
+		a.sweep = now.Add(a.Window)
+	}
+	if exp, ok := a.nonces[nonce]; ok && !now.After(exp) {
+		return false
+	}
+	// timestamps are accepted in [now-window, now+window]
+	a.nonces[nonce] = now.Add(2 * a.Window)
+	return true
+}
+
+// Sign compute signature of a request, clients set it to X-Auth-Signature after base64 encoding
+func Sign(secret []byte, r *http.Request, timestamp, nonce string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(sum[:])))
+	return m.Sum(nil)
+}
+
+// SignRequest set X-Auth-* headers of a client request
+func SignRequest(r *http.Request, key string, secret []byte, body []byte) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	n := make([]byte, 12)
+	rand.Read(n)
+	nonce := hex.EncodeToString(n)
+	r.Header.Set(HeaderKey, key)
+	r.Header.Set(HeaderTimestamp, ts)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(Sign(secret, r, ts, nonce, body)))
+}
@@ -0,0 +1,198 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	gorsa "crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/tools/encrypt/rsa"
+)
+
+var (
+	// ErrTokenMalformed token is not a jwt
+	ErrTokenMalformed = errors.New("auth: malformed token")
+
+	// ErrTokenSignature signature does not match or algorithm is not allowed
+	ErrTokenSignature = errors.New("auth: invalid token signature")
+
+	// ErrTokenExpired token has expired or is not valid yet
+	ErrTokenExpired = errors.New("auth: token expired or not valid yet")
+
+	// ErrTokenClaims issuer or audience does not match
+	ErrTokenClaims = errors.New("auth: invalid token claims")
+
+	// ErrJWTOption none or both of Secret and PublicKey are set
+	ErrJWTOption = errors.New("auth: exactly one of Secret and PublicKey should be set")
+)
+
+// JWTOption jwt options, exactly one of Secret and PublicKey should be set
+type JWTOption struct {
+	Secret    []byte           // HS256 key
+	PublicKey *gorsa.PublicKey // RS256 key
+	Issuer    string           // checked if it is not empty
+	Audience  string           // checked if it is not empty
+	Leeway    time.Duration    // clock skew allowed when checking exp and nbf
+}
+
+// JWT bearer token authentication, name of principal is claim `sub`
+type JWT struct {
+	option    JWTOption
+	Authorize func(p *Principal, r *httpserver.Request) bool // optional
+}
+
+// NewJWT return a jwt filter, ErrJWTOption is returned if option is nil or has no key
+func NewJWT(option *JWTOption) (*JWT, error) {
+	if option == nil || (len(option.Secret) == 0) == (option.PublicKey == nil) {
+		return nil, ErrJWTOption
+	}
+	return &JWT{option: *option}, nil
+}
+
+// NewRS256FromFile return a RS256 jwt filter, public key is loaded from a pem file
+func NewRS256FromFile(publicKeyFile string) (*JWT, error) {
+	key, err := rsa.GeneratePublicKeyFromFile(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewJWT(&JWTOption{PublicKey: key})
+}
+
+// Before verify bearer token
+func (a *JWT) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "Bearer ") {
+		return unauthorized(rsp, `Bearer`)
+	}
+	claims, err := a.Verify(strings.TrimSpace(h[7:]))
+	if err != nil {
+		return unauthorized(rsp, `Bearer error="invalid_token"`)
+	}
+	sub, _ := claims["sub"].(string)
+	return accept(rsp, r, &Principal{Name: sub, Scheme: "Bearer", Claims: claims}, a.Authorize)
+}
+
+// After do nothing
+func (a *JWT) After(rsp *httpserver.Response, r *httpserver.Request) {
+}
+
+// Verify verify a token and return its claims
+func (a *JWT) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	signed := parts[0] + "." + parts[1]
+	switch {
+	case header.Alg == "HS256" && len(a.option.Secret) > 0:
+		m := hmac.New(sha256.New, a.option.Secret)
+		m.Write([]byte(signed))
+		if !hmac.Equal(sig, m.Sum(nil)) {
+			return nil, ErrTokenSignature
+		}
+	case header.Alg == "RS256" && a.option.PublicKey != nil:
+		sum := sha256.Sum256([]byte(signed))
+		if gorsa.VerifyPKCS1v15(a.option.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+			return nil, ErrTokenSignature
+		}
+	default:
+		return nil, ErrTokenSignature
+	}
+	claims := make(map[string]interface{})
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(unix(exp).Add(a.option.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.option.Leeway).Before(unix(nbf)) {
+		return nil, ErrTokenExpired
+	}
+	if a.option.Issuer != "" && claims["iss"] != a.option.Issuer {
+		return nil, ErrTokenClaims
+	}
+	if a.option.Audience != "" && !hasAudience(claims["aud"], a.option.Audience) {
+		return nil, ErrTokenClaims
+	}
+	return claims, nil
+}
+
+// SignHS256 sign claims with HS256, useful to issue tokens and in tests
+func SignHS256(claims map[string]interface{}, secret []byte) (string, error) {
+	h := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := h + "." + base64.RawURLEncoding.EncodeToString(b)
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(m.Sum(nil)), nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func unix(f float64) time.Time {
+	return time.Unix(int64(f), 0)
+}
+
+func hasAudience(aud interface{}, expected string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == expected
+	case []interface{}:
+		for _, a := range v {
+			if a == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package cache response caching filter
+//
+// responses of GET requests are buffered by httpserver, cache filter stores them
+// and serves later GET and HEAD requests of the same url without calling handler function
+// e.g.
+//     c := cache.NewFilter(cache.NewLRUStore(1000), &cache.Option{TTL: time.Minute, Vary: []string{"Accept"}})
+//     s.DynamicFilter("/users/{id}", c)
+//     // in handler function
+//     cache.Tag(req, "user:"+id)
+//     // after user has been updated
+//     c.Invalidate("user:" + id)
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+const (
+	keyRequest = "cache.key"
+	tagRequest = "cache.tags"
+)
+
+// perRequestHeaders headers set for a single request, they are not stored
+var perRequestHeaders = []string{
+	"X-Request-Id",
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Expose-Headers",
+	"X-Cache",
+	"Age",
+	"Date",
+}
+
+// Entry a cached response
+type Entry struct {
+	Code    int
+	Header  http.Header
+	Body    []byte
+	Tags    []string
+	Created time.Time
+	Expires time.Time
+}
+
+// Store storage of cached responses
+type Store interface {
+	// Get returns nil, nil if key does not exist or has expired
+	Get(key string) (*Entry, error)
+	Set(key string, e *Entry) error
+	Delete(key string) error
+	// InvalidateTag delete entries tagged with tag
+	InvalidateTag(tag string) error
+}
+
+// Option cache options
+type Option struct {
+	TTL  time.Duration // default 1 minute, max-age or s-maxage of response overrides it
+	Vary []string      // request headers which are part of cache key, e.g. Accept, Accept-Encoding
+}
+
+// Filter caching filter
+// responses are cached only if status code is 200, handler function returns no error,
+// and Cache-Control of response does not say no-store, no-cache or private.
+// responses to requests with Authorization are shared only if Cache-Control says public, s-maxage or must-revalidate,
+// responses whose Vary names a header which is not in Option.Vary are not cached.
+// ETag is generated if handler function does not set one, If-None-Match is answered with 304
+type Filter struct {
+	store  Store
+	option Option
+}
+
+// NewFilter return a caching filter
+func NewFilter(store Store, option *Option) *Filter {
+	o := Option{}
+	if option != nil {
+		o = *option
+	}
+	if o.TTL <= 0 {
+		o.TTL = time.Minute
+	}
+	return &Filter{store: store, option: o}
+}
+
+// Tag tag response of request, tagged entries are deleted by Invalidate
+func Tag(req *httpserver.Request, tags ...string) {
+	old, _ := req.Get(tagRequest).([]string)
+	req.Set(tagRequest, append(old, tags...))
+}
+
+// Invalidate delete entries tagged with any of tags
+func (a *Filter) Invalidate(tags ...string) error {
+	for _, t := range tags {
+		if err := a.store.InvalidateTag(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Before serve cached response if there is one
+func (a *Filter) Before(rsp *httpserver.Response, req *httpserver.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return true
+	}
+	key := a.key(req)
+	req.Set(keyRequest, key)
+	if hasDirective(req.Header.Get("Cache-Control"), "no-cache") {
+		return true
+	}
+	e, err := a.store.Get(key)
+	if err != nil || e == nil {
+		return true
+	}
+	if req.Header.Get("Authorization") != "" && !shared(e.Header.Get("Cache-Control")) {
+		return true
+	}
+	h := rsp.Header()
+	for k, v := range e.Header {
+		h[k] = append([]string(nil), v...) // entry may be shared by other requests
+	}
+	h.Set("Age", strconv.Itoa(int(time.Since(e.Created).Seconds())))
+	h.Set("X-Cache", "HIT")
+	if etagMatch(req.Header.Get("If-None-Match"), e.Header.Get("ETag")) {
+		rsp.WriteStatusCode(http.StatusNotModified)
+		return false
+	}
+	rsp.WriteStatusCode(e.Code)
+	if req.Method != http.MethodHead {
+		rsp.Write(e.Body)
+	}
+	return false
+}
+
+// After store response
+func (a *Filter) After(rsp *httpserver.Response, req *httpserver.Request) {
+	key, ok := req.Get(keyRequest).(string)
+	if !ok || req.Method != http.MethodGet || rsp.Detached() || rsp.Err() != nil || rsp.StatusCode() != http.StatusOK {
+		return
+	}
+	h := rsp.Header()
+	cc := h.Get("Cache-Control")
+	if hasDirective(cc, "no-store") || hasDirective(cc, "no-cache") || hasDirective(cc, "private") || h.Get("Set-Cookie") != "" {
+		return
+	}
+	if req.Header.Get("Authorization") != "" && !shared(cc) {
+		return
+	}
+	if !a.keyed(h["Vary"]) {
+		return
+	}
+	ttl := a.option.TTL
+	if v, ok := directive(cc, "s-maxage"); ok {
+		ttl = time.Duration(v) * time.Second
+	} else if v, ok := directive(cc, "max-age"); ok {
+		ttl = time.Duration(v) * time.Second
+	}
+	if ttl <= 0 {
+		return
+	}
+	body := rsp.Bytes()
+	if h.Get("ETag") == "" {
+		sum := sha256.Sum256(body)
+		h.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+	header := make(http.Header, len(h))
+	for k, v := range h {
+		header[k] = append([]string(nil), v...)
+	}
+	for _, k := range perRequestHeaders {
+		header.Del(k)
+	}
+	tags, _ := req.Get(tagRequest).([]string)
+	now := time.Now()
+	a.store.Set(key, &Entry{Code: http.StatusOK, Header: header, Body: append([]byte(nil), body...), Tags: tags, Created: now, Expires: now.Add(ttl)})
+	h.Set("X-Cache", "MISS")
+	if etagMatch(req.Header.Get("If-None-Match"), h.Get("ETag")) {
+		rsp.Reset()
+		rsp.WriteStatusCode(http.StatusNotModified)
+	}
+}
+
+// key host, path, query and vary headers, HEAD is keyed as GET
+func (a *Filter) key(req *httpserver.Request) string {
+	b := &strings.Builder{}
+	b.WriteString(http.MethodGet)
+	b.WriteString(" ")
+	b.WriteString(req.Host)
+	b.WriteString(req.URL.RequestURI())
+	for _, h := range a.option.Vary {
+		b.WriteString("\n")
+		b.WriteString(h)
+		b.WriteString(":")
+		b.WriteString(req.Header.Get(h))
+	}
+	return b.String()
+}
+
+// keyed true if all headers named by Vary of response are part of cache key
+func (a *Filter) keyed(vary []string) bool {
+	for _, v := range vary {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			found := false
+			for _, k := range a.option.Vary {
+				if strings.EqualFold(k, name) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// shared true if response to a request with Authorization may be served to other requests, see RFC 9111 3.5
+func shared(cc string) bool {
+	if hasDirective(cc, "public") || hasDirective(cc, "must-revalidate") {
+		return true
+	}
+	_, ok := directive(cc, "s-maxage")
+	return ok
+}
+
+func hasDirective(cc, name string) bool {
+	for _, d := range strings.Split(cc, ",") {
+		if strings.EqualFold(strings.TrimSpace(d), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func directive(cc, name string) (int, bool) {
+	for _, d := range strings.Split(cc, ",") {
+		kv := strings.SplitN(strings.TrimSpace(d), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], name) {
+			if v, err := strconv.Atoi(strings.Trim(kv[1], `"`)); err == nil {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// etagMatch weak comparison of If-None-Match and etag
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LRUStore in memory store, least recently used entries are evicted if it is full
+type LRUStore struct {
+	capacity int
+	mutex    *sync.Mutex
+	items    map[string]*list.Element
+	order    *list.List // front is most recently used
+}
+
+type lruItem struct {
+	key   string
+	entry *Entry
+}
+
+// NewLRUStore return a LRUStore holding at most capacity entries
+func NewLRUStore(capacity int) *LRUStore {
+	return &LRUStore{capacity: capacity, mutex: new(sync.Mutex), items: make(map[string]*list.Element), order: list.New()}
+}
+
+// Get get entry
+func (a *LRUStore) Get(key string) (*Entry, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	el, c := a.items[key]
+	if !c {
+		return nil, nil
+	}
+	item := el.Value.(*lruItem)
+	if time.Now().After(item.entry.Expires) {
+		a.remove(el)
+		return nil, nil
+	}
+	a.order.MoveToFront(el)
+	return item.entry, nil
+}
+
+// Set set entry
+func (a *LRUStore) Set(key string, e *Entry) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if el, c := a.items[key]; c {
+		el.Value.(*lruItem).entry = e
+		a.order.MoveToFront(el)
+		return nil
+	}
+	a.items[key] = a.order.PushFront(&lruItem{key: key, entry: e})
+	for a.capacity > 0 && a.order.Len() > a.capacity {
+		a.remove(a.order.Back())
+	}
+	return nil
+}
+
+// Delete delete entry
+func (a *LRUStore) Delete(key string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if el, c := a.items[key]; c {
+		a.remove(el)
+	}
+	return nil
+}
+
+// InvalidateTag delete entries tagged with tag
+func (a *LRUStore) InvalidateTag(tag string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, el := range a.items {
+		if hasTag(el.Value.(*lruItem).entry, tag) {
+			a.remove(el)
+		}
+	}
+	return nil
+}
+
+// Len number of entries, including expired ones not evicted yet
+func (a *LRUStore) Len() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.order.Len()
+}
+
+func (a *LRUStore) remove(el *list.Element) {
+	a.order.Remove(el)
+	delete(a.items, el.Value.(*lruItem).key)
+}
+
+// FileStore store entries as json files in a directory, one file per entry
+// expired entries are swept by Set at most once a minute, or by DeleteExpired
+type FileStore struct {
+	dir   string
+	mutex *sync.RWMutex
+	sweep time.Time
+}
+
+// NewFileStore return a FileStore, dir is created if it does not exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, mutex: new(sync.RWMutex), sweep: time.Now()}, nil
+}
+
+func (a *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(a.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get get entry
+func (a *FileStore) Get(key string) (*Entry, error) {
+	a.mutex.RLock()
+	e, err := readEntry(a.path(key))
+	a.mutex.RUnlock()
+	if err != nil || e == nil {
+		return nil, err
+	}
+	if time.Now().After(e.Expires) {
+		return nil, a.Delete(key)
+	}
+	return e, nil
+}
+
+// Set set entry, file is replaced atomically
+func (a *FileStore) Set(key string, e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if now := time.Now(); now.Sub(a.sweep) > time.Minute {
+		a.sweep = now
+		a.removeIf(func(e *Entry) bool { return false })
+	}
+	f, err := ioutil.TempFile(a.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), a.path(key))
+}
+
+// Delete delete entry
+func (a *FileStore) Delete(key string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if err := os.Remove(a.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// InvalidateTag delete entries tagged with tag, expired entries are deleted too
+func (a *FileStore) InvalidateTag(tag string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, err := a.removeIf(func(e *Entry) bool { return hasTag(e, tag) })
+	return err
+}
+
+// DeleteExpired delete expired entries, return number of deleted entries
+func (a *FileStore) DeleteExpired() (int, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.removeIf(func(e *Entry) bool { return false })
+}
+
+// removeIf delete expired entries and entries f returns true for, mutex must be held
+func (a *FileStore) removeIf(f func(e *Entry) bool) (int, error) {
+	files, err := filepath.Glob(filepath.Join(a.dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	n := 0
+	for _, file := range files {
+		e, err := readEntry(file)
+		if err != nil || e == nil {
+			continue
+		}
+		if now.After(e.Expires) || f(e) {
+			if os.Remove(file) == nil {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+func readEntry(path string) (*Entry, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	e := &Entry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func hasTag(e *Entry, tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
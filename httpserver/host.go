@@ -0,0 +1,268 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"crypto/tls"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// VirtualHost routes and filters of a host
+// settings of server such as logger, metrics, body limit and versioning are shared,
+// NotFound and ErrorHandler of server are used unless they are set on host
+type VirtualHost struct {
+	pattern         string
+	regex           *regexp.Regexp // nil if pattern is an exact host
+	params          []string
+	h               *handler
+	notFound        func(rsp *Response, req *Request) (uint, error)
+	errorHandler    func(rsp *Response, req *Request, code uint, err error)
+	errorHandlerSet bool
+	cert            *certReloader
+	done            chan struct{} // done of server, stops health check of proxies
+}
+
+var hostParamRegex = regexp.MustCompile(`\*|{\w+}`)
+
+// Host get routes of host pattern, requests of other hosts are served by routes of server
+// pattern is an exact host, or a wildcard host where `*` or `{name}` matches a label,
+// value of `{name}` is got by req.GetDynamicParam(name)
+// e.g.
+//     api := s.Host("api.example.com")
+//     api.Route("/users", users)
+//     tenant := s.Host("{tenant}.example.com")
+//     tenant.DynamicRoute("/home/{page}", home)
+func (a *HTTPServer) Host(pattern string) *VirtualHost {
+	pattern = strings.ToLower(pattern)
+	for _, vh := range a.defaultHandler.hosts {
+		if vh.pattern == pattern {
+			return vh
+		}
+	}
+	vh := &VirtualHost{pattern: pattern, h: newHandler(), done: a.done}
+	vh.h.versioning = a.defaultHandler.versioning
+	if hostParamRegex.MatchString(pattern) {
+		params := make([]string, 0)
+		expr := ""
+		last := 0
+		for _, loc := range hostParamRegex.FindAllStringIndex(pattern, -1) {
+			expr += regexp.QuoteMeta(pattern[last:loc[0]])
+			if p := pattern[loc[0]:loc[1]]; p == "*" {
+				expr += `[^.]+`
+			} else {
+				expr += `([^.]+)`
+				params = append(params, p[1:len(p)-1])
+			}
+			last = loc[1]
+		}
+		expr += regexp.QuoteMeta(pattern[last:])
+		vh.regex = regexp.MustCompile("^" + expr + "$")
+		vh.params = params
+	}
+	a.defaultHandler.hosts = append(a.defaultHandler.hosts, vh)
+	return vh
+}
+
+// Route register a static urlpath of host
+func (a *VirtualHost) Route(path string, f func(rsp *Response, req *Request) (uint, error)) {
+	a.h.route(path, f)
+}
+
+// DynamicRoute register a dynamic urlpath of host
+func (a *VirtualHost) DynamicRoute(pattern string, f func(rsp *Response, req *Request) (uint, error)) {
+	a.h.dynamicRoute(pattern, f)
+}
+
+// RouteWith register a plain urlpath of host with constraints, see HTTPServer.RouteWith
+func (a *VirtualHost) RouteWith(path string, c *Constraint, f func(rsp *Response, req *Request) (uint, error)) {
+	a.h.routeWith(path, c, f)
+}
+
+// DynamicRouteWith register a dynamic urlpath of host with constraints, see HTTPServer.RouteWith
+func (a *VirtualHost) DynamicRouteWith(pattern string, c *Constraint, f func(rsp *Response, req *Request) (uint, error)) {
+	a.h.dynamicRouteWith(pattern, c, f)
+}
+
+// Static serve files under dir with url prefix of host
+func (a *VirtualHost) Static(prefix, dir string) {
+	a.h.static(prefix, dir, nil)
+}
+
+// StaticWithOption serve files under dir with url prefix of host
+func (a *VirtualHost) StaticWithOption(prefix, dir string, option *StaticOption) {
+	a.h.static(prefix, dir, option)
+}
+
+// SPA serve a single page application under dir with url prefix of host, see HTTPServer.SPA
+func (a *VirtualHost) SPA(prefix, dir, index string) {
+	a.h.spa(prefix, dir, index)
+}
+
+// WebSocket register a websocket endpoint of host
+func (a *VirtualHost) WebSocket(pattern string, f func(conn *WSConn, req *Request)) {
+	a.h.webSocket(pattern, nil, f)
+}
+
+// WebSocketWithOption register a websocket endpoint of host with option
+func (a *VirtualHost) WebSocketWithOption(pattern string, option *WSOption, f func(conn *WSConn, req *Request)) {
+	a.h.webSocket(pattern, option, f)
+}
+
+// SSE register a server-sent events endpoint of host
+func (a *VirtualHost) SSE(pattern string, f func(stream *EventStream, req *Request)) {
+	a.h.sse(pattern, f)
+}
+
+// Proxy forward requests of host matching pattern to targets, see HTTPServer.Proxy
+func (a *VirtualHost) Proxy(pattern string, targets []string, option *ProxyOption) error {
+	return a.h.proxy(pattern, targets, option, a.done)
+}
+
+// Upload set upload options of route pattern of host
+func (a *VirtualHost) Upload(pattern string, option *UploadOption) {
+	a.h.uploads[pattern] = option
+}
+
+// Describe add openapi metadata to route pattern of host
+func (a *VirtualHost) Describe(pattern string, doc *RouteDoc) {
+	a.h.describe(pattern, doc)
+}
+
+// OpenAPI serve an openapi 3 document of routes of host at path
+func (a *VirtualHost) OpenAPI(path string, info *OpenAPIInfo) {
+	a.h.openAPIRoute(path, info)
+}
+
+// Filter register a filter of a static urlpath of host
+func (a *VirtualHost) Filter(path string, f Filter) {
+	a.h.filter(path, f, 0)
+}
+
+// FilterWithPriority register a filter of a static urlpath of host with priority
+func (a *VirtualHost) FilterWithPriority(path string, f Filter, priority int) {
+	a.h.filter(path, f, priority)
+}
+
+// DynamicFilter register a filter of a dynamic urlpath of host
+func (a *VirtualHost) DynamicFilter(pattern string, f Filter) {
+	a.h.dynamicFilter(pattern, f, 0)
+}
+
+// DynamicFilterWithPriority register a filter of a dynamic urlpath of host with priority
+func (a *VirtualHost) DynamicFilterWithPriority(pattern string, f Filter, priority int) {
+	a.h.dynamicFilter(pattern, f, priority)
+}
+
+// NotFound set 404 handler function of host
+func (a *VirtualHost) NotFound(f func(rsp *Response, req *Request) (uint, error)) {
+	a.notFound = f
+}
+
+// ErrorHandler set error handler function of host, set nil to keep what handler function has writen
+func (a *VirtualHost) ErrorHandler(f func(rsp *Response, req *Request, code uint, err error)) {
+	a.errorHandler = f
+	a.errorHandlerSet = true
+}
+
+// Certificate set certificate of host, it is selected by SNI in ServeHTTPS
+// certificate files are reloaded as TLSOption.ReloadInterval says
+func (a *VirtualHost) Certificate(certFile, keyFile string) error {
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	a.cert = r
+	return nil
+}
+
+// match return params captured from host, nil if host does not match
+func (a *VirtualHost) match(host string) (map[string]string, bool) {
+	if a.regex == nil {
+		return nil, a.pattern == host
+	}
+	values := a.regex.FindStringSubmatch(host)
+	if values == nil {
+		return nil, false
+	}
+	params := make(map[string]string, len(a.params))
+	for i, p := range a.params {
+		params[p] = values[i+1]
+	}
+	return params, true
+}
+
+// matchHost exact hosts are matched before wildcard hosts, wildcard hosts are matched in registration order
+func (a *handler) matchHost(host string, withCert bool) (*VirtualHost, map[string]string) {
+	if len(a.hosts) == 0 {
+		return nil, nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, exact := range []bool{true, false} {
+		for _, vh := range a.hosts {
+			if (vh.regex == nil) != exact || withCert && vh.cert == nil {
+				continue
+			}
+			if params, ok := vh.match(host); ok {
+				return vh, params
+			}
+		}
+	}
+	return nil, nil
+}
+
+// prepareHosts share settings of server with hosts
+func (a *handler) prepareHosts() {
+	for _, vh := range a.hosts {
+		h := vh.h
+		h.logger = a.logger
+		h.maxBodyBytes = a.maxBodyBytes
+		h.metrics = a.metrics
+		h.versioning = a.versioning
+		h.notFound = a.notFound
+		if vh.notFound != nil {
+			h.notFound = vh.notFound
+		}
+		h.errorHandler = a.errorHandler
+		if vh.errorHandlerSet {
+			h.errorHandler = vh.errorHandler
+		}
+		h.prepare()
+	}
+}
+
+// hostCertificate select certificate of host by SNI, fallback is used if no host matches
+func (a *handler) hostCertificate(fallback func(*tls.ClientHelloInfo) (*tls.Certificate, error)) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if vh, _ := a.matchHost(hello.ServerName, true); vh != nil {
+			return vh.cert.get(hello)
+		}
+		return fallback(hello)
+	}
+}
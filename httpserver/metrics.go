@@ -0,0 +1,108 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/metrics"
+)
+
+const (
+	notFoundRoute = "NOT_FOUND"
+	otherMethod   = "OTHER"
+)
+
+var sizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+type httpMetrics struct {
+	requests *metrics.Counter
+	duration *metrics.Histogram
+	inFlight *metrics.Gauge
+	size     *metrics.Histogram
+}
+
+func newHTTPMetrics(r *metrics.Registry) *httpMetrics {
+	return &httpMetrics{
+		requests: r.Counter("http_requests_total", "Count of http requests.", "route", "method", "code"),
+		duration: r.Histogram("http_request_duration_seconds", "Latency of http requests in seconds.", nil, "route", "method"),
+		inFlight: r.Gauge("http_requests_in_flight", "Count of http requests being served.", "route"),
+		size:     r.Histogram("http_response_size_bytes", "Size of http responses in bytes.", sizeBuckets, "route", "code"),
+	}
+}
+
+func routeLabel(req *Request) string {
+	if req.pattern == "" {
+		return notFoundRoute
+	}
+	return req.pattern
+}
+
+// methodLabel unknown methods are labeled OTHER, so that clients can not create unlimited series
+func methodLabel(req *Request) string {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return req.Method
+	}
+	return otherMethod
+}
+
+func (a *httpMetrics) begin(req *Request) {
+	a.inFlight.Inc(routeLabel(req))
+}
+
+func (a *httpMetrics) end(req *Request, rsp *Response, start time.Time) {
+	route, method := routeLabel(req), methodLabel(req)
+	code := strconv.Itoa(rsp.StatusCode())
+	a.inFlight.Dec(route)
+	a.requests.Inc(route, method, code)
+	a.duration.Observe(time.Since(start).Seconds(), route, method)
+	a.size.Observe(float64(len(rsp.b)), route, code)
+}
+
+// Metrics collect metrics of requests into metrics.DefaultRegistry, and expose them on path in prometheus text format
+// metrics are labeled by route pattern instead of url, e.g. /a/{id}
+// orm and job can register their metrics in the same registry, see orm.RegisterMetrics and JobQueue.RegisterMetrics
+func (a *HTTPServer) Metrics(path string) {
+	a.MetricsWithRegistry(path, metrics.DefaultRegistry)
+}
+
+// MetricsWithRegistry same as Metrics, but use registry r
+func (a *HTTPServer) MetricsWithRegistry(path string, r *metrics.Registry) {
+	a.defaultHandler.metrics = newHTTPMetrics(r)
+	a.Route(path, func(rsp *Response, req *Request) (uint, error) {
+		b := new(bytes.Buffer)
+		if err := r.WriteText(b); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		rsp.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		rsp.Write(b.Bytes())
+		return http.StatusOK, nil
+	})
+}
@@ -0,0 +1,114 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package middleware common filters for httpserver
+package middleware
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/log"
+)
+
+const (
+	// CommonLog NCSA common log format
+	CommonLog = iota
+
+	// JSONLog one json object per line
+	JSONLog
+)
+
+const accessLogStartKey = "middleware.accesslog.start"
+
+// AccessLog write one line for each request through logger
+type AccessLog struct {
+	logger *log.Logger
+	format int
+}
+
+// NewAccessLog return an access log filter
+// format is CommonLog or JSONLog
+func NewAccessLog(logger *log.Logger, format int) *AccessLog {
+	return &AccessLog{logger: logger, format: format}
+}
+
+// Before record start time
+func (a *AccessLog) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	r.Set(accessLogStartKey, time.Now())
+	return true
+}
+
+// After write access log
+func (a *AccessLog) After(rsp *httpserver.Response, r *httpserver.Request) {
+	start, _ := r.Get(accessLogStartKey).(time.Time)
+	if a.format == JSONLog {
+		a.logger.Info("%s", a.json(rsp, r, start))
+	} else {
+		a.logger.Info("%s", a.common(rsp, r, start))
+	}
+}
+
+func (a *AccessLog) common(rsp *httpserver.Response, r *httpserver.Request, start time.Time) string {
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	size := "-"
+	if len(rsp.Bytes()) > 0 {
+		size = strconv.Itoa(len(rsp.Bytes()))
+	}
+	return remoteHost(r) + " - " + user + " [" + start.Format("02/Jan/2006:15:04:05 -0700") + "] \"" +
+		r.Method + " " + r.RequestURI + " " + r.Proto + "\" " + strconv.Itoa(rsp.StatusCode()) + " " + size
+}
+
+func (a *AccessLog) json(rsp *httpserver.Response, r *httpserver.Request, start time.Time) string {
+	m := map[string]interface{}{
+		"time":        start.Format(time.RFC3339Nano),
+		"remote":      remoteHost(r),
+		"method":      r.Method,
+		"uri":         r.RequestURI,
+		"proto":       r.Proto,
+		"status":      rsp.StatusCode(),
+		"size":        len(rsp.Bytes()),
+		"duration_ms": float64(time.Since(start)) / float64(time.Millisecond),
+		"user_agent":  r.UserAgent(),
+	}
+	if id := RequestID(r); id != "" {
+		m["request_id"] = id
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+func remoteHost(r *httpserver.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,135 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"strings"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+// Compress compress buffered response body with gzip or deflate
+// according to Accept-Encoding of request
+type Compress struct {
+	level   int
+	minSize int
+}
+
+// NewCompress return a compress filter
+// level is compression level of compress/flate, bodies shorter than minSize are not compressed
+func NewCompress(level, minSize int) *Compress {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = flate.DefaultCompression
+	}
+	return &Compress{level: level, minSize: minSize}
+}
+
+// Before do nothing
+func (a *Compress) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	return true
+}
+
+// After compress response body, body is sent as is if header has been written
+func (a *Compress) After(rsp *httpserver.Response, r *httpserver.Request) {
+	if rsp.HeaderWritten() {
+		return
+	}
+	b := rsp.Bytes()
+	code := rsp.StatusCode()
+	if len(b) == 0 || len(b) < a.minSize || code == http.StatusNoContent || code == http.StatusNotModified {
+		return
+	}
+	h := rsp.Header()
+	if h.Get("Content-Encoding") != "" {
+		return
+	}
+	encoding := acceptEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return
+	}
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(b))
+	}
+	buf := new(bytes.Buffer)
+	var err error
+	if encoding == "gzip" {
+		var w *gzip.Writer
+		if w, err = gzip.NewWriterLevel(buf, a.level); err == nil {
+			if _, err = w.Write(b); err == nil {
+				err = w.Close()
+			}
+		}
+	} else {
+		// http deflate is zlib format, not raw deflate, see RFC 9110 8.4.1.2
+		var w *zlib.Writer
+		if w, err = zlib.NewWriterLevel(buf, a.level); err == nil {
+			if _, err = w.Write(b); err == nil {
+				err = w.Close()
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+	h.Set("Content-Encoding", encoding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+	rsp.Reset()
+	rsp.Write(buf.Bytes())
+}
+
+// acceptEncoding choose gzip or deflate, gzip is preferred
+func acceptEncoding(s string) string {
+	gz, df := false, false
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		q := ""
+		if i := strings.Index(e, ";"); i >= 0 {
+			q = strings.Replace(e[i+1:], " ", "", -1)
+			e = strings.TrimSpace(e[:i])
+		}
+		if q == "q=0" || q == "q=0.0" || q == "q=0.00" || q == "q=0.000" {
+			continue
+		}
+		switch strings.ToLower(e) {
+		case "gzip", "*":
+			gz = true
+		case "deflate":
+			df = true
+		}
+	}
+	if gz {
+		return "gzip"
+	}
+	if df {
+		return "deflate"
+	}
+	return ""
+}
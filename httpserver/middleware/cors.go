@@ -0,0 +1,137 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+// CORSOption cors options
+type CORSOption struct {
+	AllowOrigins     []string // "*" allows any origin, default "*"
+	AllowMethods     []string // default GET, POST, PUT, DELETE, PATCH, HEAD
+	AllowHeaders     []string // default echoes Access-Control-Request-Headers
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int // seconds, preflight result is not cached if MaxAge <= 0
+}
+
+// CORS cross-origin resource sharing filter
+// preflight requests are answered with 204 and handler function will not work
+type CORS struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	methods     string
+	headers     string
+	expose      string
+	credentials bool
+	maxAge      int
+}
+
+// NewCORS return a cors filter
+func NewCORS(option *CORSOption) *CORS {
+	if option == nil {
+		option = &CORSOption{}
+	}
+	c := &CORS{
+		origins:     make(map[string]bool),
+		headers:     strings.Join(option.AllowHeaders, ", "),
+		expose:      strings.Join(option.ExposeHeaders, ", "),
+		credentials: option.AllowCredentials,
+		maxAge:      option.MaxAge,
+	}
+	if len(option.AllowOrigins) == 0 {
+		c.anyOrigin = true
+	}
+	for _, o := range option.AllowOrigins {
+		if o == "*" {
+			c.anyOrigin = true
+		}
+		c.origins[strings.ToLower(o)] = true
+	}
+	methods := option.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodHead}
+	}
+	c.methods = strings.ToUpper(strings.Join(methods, ", "))
+	return c
+}
+
+// Before write cors headers, answer preflight requests
+func (a *CORS) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	h := rsp.Header()
+	h.Add("Vary", "Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if !a.allowed(origin) {
+		if preflight {
+			rsp.WriteStatusCode(http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	if a.anyOrigin && !a.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if a.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if a.expose != "" {
+			h.Set("Access-Control-Expose-Headers", a.expose)
+		}
+		return true
+	}
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", a.methods)
+	if a.headers != "" {
+		h.Set("Access-Control-Allow-Headers", a.headers)
+	} else if rh := r.Header.Get("Access-Control-Request-Headers"); rh != "" {
+		h.Set("Access-Control-Allow-Headers", rh)
+	}
+	if a.maxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(a.maxAge))
+	}
+	rsp.WriteStatusCode(http.StatusNoContent)
+	return false
+}
+
+// After do nothing
+func (a *CORS) After(rsp *httpserver.Response, r *httpserver.Request) {
+}
+
+func (a *CORS) allowed(origin string) bool {
+	return a.anyOrigin || a.origins[strings.ToLower(origin)]
+}
@@ -0,0 +1,133 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+// KeyByIP rate limit key: remote ip
+func KeyByIP(r *httpserver.Request) string {
+	return remoteHost(r)
+}
+
+// KeyByHeader rate limit key: value of header, e.g. X-API-Key
+func KeyByHeader(header string) func(r *httpserver.Request) string {
+	return func(r *httpserver.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimit token bucket rate limiter
+// requests beyond limit are answered with 429 and handler function will not work
+type RateLimit struct {
+	rate    float64 // tokens per second
+	burst   float64
+	key     func(r *httpserver.Request) string
+	mutex   *sync.Mutex
+	buckets map[string]*bucket
+	sweep   time.Time
+}
+
+// NewRateLimit return a rate limit filter
+// rate is requests allowed per second, burst is the capacity of bucket
+// key is KeyByIP if it is nil
+func NewRateLimit(rate float64, burst int, key func(r *httpserver.Request) string) *RateLimit {
+	if key == nil {
+		key = KeyByIP
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimit{
+		rate:    rate,
+		burst:   float64(burst),
+		key:     key,
+		mutex:   new(sync.Mutex),
+		buckets: make(map[string]*bucket),
+		sweep:   time.Now(),
+	}
+}
+
+// Before take a token
+func (a *RateLimit) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	ok, wait := a.take(a.key(r), time.Now())
+	if ok {
+		return true
+	}
+	rsp.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	rsp.WriteStatusCode(http.StatusTooManyRequests)
+	return false
+}
+
+// After do nothing
+func (a *RateLimit) After(rsp *httpserver.Response, r *httpserver.Request) {
+}
+
+// take returns whether a token is taken, or how long to wait for next one
+func (a *RateLimit) take(key string, now time.Time) (bool, time.Duration) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clean(now)
+	b, c := a.buckets[key]
+	if !c {
+		b = &bucket{tokens: a.burst, last: now}
+		a.buckets[key] = b
+	}
+	b.tokens = math.Min(a.burst, b.tokens+now.Sub(b.last).Seconds()*a.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if a.rate <= 0 {
+		return false, time.Second
+	}
+	return false, time.Duration((1 - b.tokens) / a.rate * float64(time.Second))
+}
+
+// clean remove full buckets once a minute
+func (a *RateLimit) clean(now time.Time) {
+	if now.Sub(a.sweep) < time.Minute {
+		return
+	}
+	a.sweep = now
+	for k, b := range a.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*a.rate >= a.burst {
+			delete(a.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+// RequestIDHeader header name of request id
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "middleware.requestid"
+
+// RequestIDFilter use X-Request-ID of request, or generate one if it is absent,
+// and write it back to response header
+type RequestIDFilter struct {
+}
+
+// NewRequestID return a request id filter
+func NewRequestID() *RequestIDFilter {
+	return &RequestIDFilter{}
+}
+
+// Before prepare request id
+func (a *RequestIDFilter) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > 128 {
+		id = newRequestID()
+	}
+	r.Set(requestIDKey, id)
+	rsp.Header().Set(RequestIDHeader, id)
+	return true
+}
+
+// After do nothing
+func (a *RequestIDFilter) After(rsp *httpserver.Response, r *httpserver.Request) {
+}
+
+// RequestID get request id prepared by RequestIDFilter
+func RequestID(r *httpserver.Request) string {
+	if id, ok := r.Get(requestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
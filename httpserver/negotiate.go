@@ -0,0 +1,286 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotAcceptable no route produces a media type accepted by request
+	ErrNotAcceptable = errors.New("httpserver: not acceptable")
+
+	// ErrUnsupportedMediaType no route consumes content type of request
+	ErrUnsupportedMediaType = errors.New("httpserver: unsupported media type")
+)
+
+// Constraint route constraints, empty fields match any request
+type Constraint struct {
+	Produces []string // media types written by handler, matched against Accept, e.g. application/json
+	Consumes []string // media types read by handler, matched against Content-Type
+	Version  string   // api version, see Versioning
+}
+
+// VersionOption how api version of a request is selected
+type VersionOption struct {
+	Header     string // header carrying version, default API-Version
+	Default    string // version of requests without version header
+	PathPrefix bool   // route of version v is registered under /v, e.g. /v2/users, Header is ignored
+}
+
+type variant struct {
+	constraint Constraint
+	f          func(rsp *Response, req *Request) (uint, error)
+}
+
+// Versioning set how api version of a request is selected, call it before registering routes
+func (a *HTTPServer) Versioning(option *VersionOption) {
+	if option == nil {
+		option = &VersionOption{}
+	}
+	o := *option
+	if o.Header == "" {
+		o.Header = "API-Version"
+	}
+	a.defaultHandler.versioning = &o
+	for _, vh := range a.defaultHandler.hosts {
+		vh.h.versioning = &o
+	}
+}
+
+// RouteWith register a plain urlpath with constraints
+// a path can be registered several times with different constraints,
+// the variant that best matches Accept, Content-Type and version of request is called,
+// otherwise the request is refused with 406 or 415
+// e.g.
+//     s.RouteWith("/users", &httpserver.Constraint{Produces: []string{"application/json"}}, jsonUsers)
+//     s.RouteWith("/users", &httpserver.Constraint{Produces: []string{"application/x-protobuf"}}, pbUsers)
+func (a *HTTPServer) RouteWith(path string, c *Constraint, f func(rsp *Response, req *Request) (uint, error)) {
+	a.defaultHandler.routeWith(path, c, f)
+}
+
+// DynamicRouteWith register a dynamic urlpath with constraints, see RouteWith
+func (a *HTTPServer) DynamicRouteWith(pattern string, c *Constraint, f func(rsp *Response, req *Request) (uint, error)) {
+	a.defaultHandler.dynamicRouteWith(pattern, c, f)
+}
+
+// MediaType get media type negotiated for response, empty if route has no Produces constraint
+func (a *Request) MediaType() string {
+	return a.mediaType
+}
+
+// Version get api version of request
+func (a *Request) Version() string {
+	return a.version
+}
+
+func (a *handler) routeWith(path string, c *Constraint, f func(rsp *Response, req *Request) (uint, error)) {
+	path = a.addVariant(path, c, f)
+	a.route(path, a.negotiate(path))
+}
+
+func (a *handler) dynamicRouteWith(pattern string, c *Constraint, f func(rsp *Response, req *Request) (uint, error)) {
+	pattern = a.addVariant(pattern, c, f)
+	a.dynamicRoute(pattern, a.negotiate(pattern))
+}
+
+// addVariant return path the variant is registered under
+func (a *handler) addVariant(path string, c *Constraint, f func(rsp *Response, req *Request) (uint, error)) string {
+	if c == nil {
+		c = &Constraint{}
+	}
+	if a.versioning != nil && a.versioning.PathPrefix && c.Version != "" {
+		path = "/" + strings.Trim(c.Version, "/") + path
+	}
+	a.variants[path] = append(a.variants[path], &variant{constraint: *c, f: f})
+	return path
+}
+
+// negotiate return handler function choosing the best variant of path
+func (a *handler) negotiate(path string) func(rsp *Response, req *Request) (uint, error) {
+	return func(rsp *Response, req *Request) (uint, error) {
+		candidates := a.variants[path]
+		req.version = a.requestVersion(req)
+		if req.version != "" {
+			matched := make([]*variant, 0, len(candidates))
+			for _, v := range candidates {
+				if v.constraint.Version == "" || v.constraint.Version == req.version {
+					matched = append(matched, v)
+				}
+			}
+			if len(matched) == 0 {
+				return a.notFound(rsp, req)
+			}
+			candidates = matched
+		}
+
+		if req.ContentLength != 0 || req.Header.Get("Content-Type") != "" {
+			ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			matched := make([]*variant, 0, len(candidates))
+			for _, v := range candidates {
+				if len(v.constraint.Consumes) == 0 || containsMediaType(v.constraint.Consumes, ct) {
+					matched = append(matched, v)
+				}
+			}
+			if len(matched) == 0 {
+				return http.StatusUnsupportedMediaType, ErrUnsupportedMediaType
+			}
+			candidates = matched
+		}
+
+		accept := parseAccept(req.Header.Get("Accept"))
+		var best *variant
+		bestQ := 0.0
+		for _, v := range candidates {
+			q, mt := acceptQuality(accept, v.constraint.Produces)
+			if q > bestQ {
+				best, bestQ, req.mediaType = v, q, mt
+			}
+		}
+		if best == nil {
+			return http.StatusNotAcceptable, ErrNotAcceptable
+		}
+		if len(a.variants[path]) > 1 {
+			rsp.Header().Add("Vary", "Accept")
+		}
+		if req.mediaType != "" && rsp.Header().Get("Content-Type") == "" {
+			rsp.Header().Set("Content-Type", req.mediaType)
+		}
+		return best.f(rsp, req)
+	}
+}
+
+// negotiationMiss true if err is a 406 or 415 caused by client, it is not logged
+func negotiationMiss(err error) bool {
+	return err == ErrNotAcceptable || err == ErrUnsupportedMediaType
+}
+
+func (a *handler) requestVersion(req *Request) string {
+	if a.versioning == nil {
+		return ""
+	}
+	if a.versioning.PathPrefix {
+		if p := strings.SplitN(strings.TrimPrefix(req.pattern, "/"), "/", 2); len(p) > 0 {
+			for _, v := range a.variants[req.pattern] {
+				if v.constraint.Version == p[0] {
+					return p[0]
+				}
+			}
+		}
+		return ""
+	}
+	if v := req.Header.Get(a.versioning.Header); v != "" {
+		return v
+	}
+	return a.versioning.Default
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(s string) []acceptRange {
+	if strings.TrimSpace(s) == "" {
+		return []acceptRange{{"*/*", 1}}
+	}
+	ranges := make([]acceptRange, 0)
+	for _, part := range strings.Split(s, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, c := params["q"]; c {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		ranges = append(ranges, acceptRange{mt, q})
+	}
+	return ranges
+}
+
+// acceptQuality return the highest quality of produced media types and the media type
+// a route producing nothing accepts anything
+func acceptQuality(accept []acceptRange, produces []string) (float64, string) {
+	if len(produces) == 0 {
+		best := 0.0
+		for _, r := range accept {
+			if r.q > best {
+				best = r.q
+			}
+		}
+		return best, ""
+	}
+	best, mt := 0.0, ""
+	for _, p := range produces {
+		if q := mediaTypeQuality(accept, p); q > best {
+			best, mt = q, p
+		}
+	}
+	return best, mt
+}
+
+// mediaTypeQuality quality of mt is given by the most specific range matching it, see RFC 7231 5.3.2
+// e.g. text/html;q=0 excludes text/html even if */* is accepted
+func mediaTypeQuality(accept []acceptRange, mt string) float64 {
+	q, specificity := 0.0, 0
+	for _, r := range accept {
+		if !matchMediaType(r.mediaType, mt) {
+			continue
+		}
+		s := 3
+		if r.mediaType == "*/*" {
+			s = 1
+		} else if strings.HasSuffix(r.mediaType, "/*") {
+			s = 2
+		}
+		if s > specificity || s == specificity && r.q > q {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+func matchMediaType(pattern, mt string) bool {
+	if pattern == "*/*" || pattern == mt {
+		return true
+	}
+	return strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mt, pattern[:len(pattern)-1])
+}
+
+func containsMediaType(types []string, mt string) bool {
+	for _, t := range types {
+		if matchMediaType(t, mt) {
+			return true
+		}
+	}
+	return false
+}
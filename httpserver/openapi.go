@@ -0,0 +1,316 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouteDoc openapi metadata of an operation on a route
+type RouteDoc struct {
+	Method      string // default GET
+	Summary     string
+	Description string
+	Tags        []string
+	Params      []*ParamDoc         // query, header or cookie params, path params are derived from pattern
+	Request     interface{}         // value of request body type, e.g. User{} or []*User(nil)
+	Response    interface{}         // value of 200 response body type
+	Responses   map[int]interface{} // other responses, status code -> value of body type, nil for no body
+}
+
+// ParamDoc openapi metadata of a parameter
+type ParamDoc struct {
+	Name        string
+	In          string // query, header or cookie, default query
+	Description string
+	Required    bool
+	Type        interface{} // value of param type, default string
+}
+
+// OpenAPIInfo info object of openapi document
+type OpenAPIInfo struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+var pathParamRegex = regexp.MustCompile(`{(\w+)}`)
+
+// Describe add openapi metadata to route pattern, call it once per method
+// e.g.
+//     s.DynamicRoute("/users/{id}", getUser)
+//     s.Describe("/users/{id}", &httpserver.RouteDoc{Summary: "get user", Response: User{}})
+func (a *HTTPServer) Describe(pattern string, doc *RouteDoc) {
+	a.defaultHandler.describe(pattern, doc)
+}
+
+// OpenAPI serve an openapi 3 document of registered routes at path
+// routes without metadata are documented as GET operations, prefix routes are not documented
+func (a *HTTPServer) OpenAPI(path string, info *OpenAPIInfo) {
+	a.defaultHandler.openAPIRoute(path, info)
+}
+
+func (a *handler) describe(pattern string, doc *RouteDoc) {
+	a.docs[pattern] = append(a.docs[pattern], doc)
+}
+
+func (a *handler) openAPIRoute(path string, info *OpenAPIInfo) {
+	if info == nil {
+		info = &OpenAPIInfo{Title: "API", Version: "1.0.0"}
+	}
+	a.route(path, func(rsp *Response, req *Request) (uint, error) {
+		b, err := json.MarshalIndent(a.openAPI(info, path), "", "  ")
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		rsp.Header().Set("Content-Type", "application/json")
+		rsp.Write(b)
+		return http.StatusOK, nil
+	})
+}
+
+func (a *handler) openAPI(info *OpenAPIInfo, self string) map[string]interface{} {
+	sb := &schemaBuilder{components: make(map[string]interface{}), types: make(map[string]reflect.Type)}
+	paths := make(map[string]interface{})
+	for _, r := range a.routes() {
+		if r.Kind == "prefix" || r.Pattern == self {
+			continue
+		}
+		docs := a.docs[r.Pattern]
+		if len(docs) == 0 {
+			docs = []*RouteDoc{{}}
+		}
+		consumes, produces := a.mediaTypes(r.Pattern)
+		ops := make(map[string]interface{})
+		for _, d := range docs {
+			method := strings.ToLower(d.Method)
+			if method == "" {
+				method = "get"
+			}
+			ops[method] = sb.operation(r.Pattern, d, consumes, produces)
+		}
+		paths[r.Pattern] = ops
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": info.Title, "version": info.Version, "description": info.Description},
+		"paths":   paths,
+	}
+	if len(sb.components) > 0 {
+		doc["components"] = map[string]interface{}{"schemas": sb.components}
+	}
+	return doc
+}
+
+// mediaTypes media types of constraints registered by RouteWith, default application/json
+func (a *handler) mediaTypes(pattern string) ([]string, []string) {
+	var consumes, produces []string
+	for _, v := range a.variants[pattern] {
+		consumes = append(consumes, v.constraint.Consumes...)
+		produces = append(produces, v.constraint.Produces...)
+	}
+	if len(consumes) == 0 {
+		consumes = []string{"application/json"}
+	}
+	if len(produces) == 0 {
+		produces = []string{"application/json"}
+	}
+	return consumes, produces
+}
+
+type schemaBuilder struct {
+	components map[string]interface{}
+	types      map[string]reflect.Type // component name -> type
+}
+
+func (a *schemaBuilder) operation(pattern string, d *RouteDoc, consumes, produces []string) map[string]interface{} {
+	op := map[string]interface{}{}
+	if d.Summary != "" {
+		op["summary"] = d.Summary
+	}
+	if d.Description != "" {
+		op["description"] = d.Description
+	}
+	if len(d.Tags) > 0 {
+		op["tags"] = d.Tags
+	}
+	params := make([]interface{}, 0)
+	for _, m := range pathParamRegex.FindAllStringSubmatch(pattern, -1) {
+		params = append(params, map[string]interface{}{"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+	}
+	for _, p := range d.Params {
+		in := p.In
+		if in == "" {
+			in = "query"
+		}
+		schema := map[string]interface{}{"type": "string"}
+		if p.Type != nil {
+			schema = a.schema(reflect.TypeOf(p.Type))
+		}
+		param := map[string]interface{}{"name": p.Name, "in": in, "schema": schema}
+		if p.Required {
+			param["required"] = true
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if d.Request != nil {
+		op["requestBody"] = map[string]interface{}{"required": true, "content": a.content(d.Request, consumes)}
+	}
+	responses := map[string]interface{}{}
+	ok := map[string]interface{}{"description": http.StatusText(http.StatusOK)}
+	if d.Response != nil {
+		ok["content"] = a.content(d.Response, produces)
+	}
+	responses["200"] = ok
+	for code, body := range d.Responses {
+		r := map[string]interface{}{"description": http.StatusText(code)}
+		if body != nil {
+			r["content"] = a.content(body, produces)
+		}
+		responses[strconv.Itoa(code)] = r
+	}
+	op["responses"] = responses
+	return op
+}
+
+func (a *schemaBuilder) content(v interface{}, mediaTypes []string) map[string]interface{} {
+	schema := a.schema(reflect.TypeOf(v))
+	c := make(map[string]interface{})
+	for _, mt := range mediaTypes {
+		c[mt] = map[string]interface{}{"schema": schema}
+	}
+	return c
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema json schema of t, named structs are put into components
+func (a *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": a.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": a.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return a.object(t)
+		}
+		name := a.componentName(t)
+		if _, c := a.components[name]; !c {
+			a.components[name] = map[string]interface{}{} // placeholder for recursive types
+			a.components[name] = a.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func (a *schemaBuilder) componentName(t reflect.Type) string {
+	name := t.Name()
+	if other, c := a.types[name]; c && other != t {
+		name = strings.Replace(t.String(), ".", "_", -1)
+	}
+	a.types[name] = t
+	return name
+}
+
+// object schema of struct fields, json tags are respected, fields of embedded structs are inlined
+func (a *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	a.fields(t, properties, &required)
+	o := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		o["required"] = required
+	}
+	return o
+}
+
+func (a *schemaBuilder) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			a.fields(ft, properties, required)
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = a.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ServerOption server options
+type ServerOption struct {
+	Port              int
+	Addr              string // host:port to bind, overrides Port, e.g. 127.0.0.1:9090
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int   // http.DefaultMaxHeaderBytes if MaxHeaderBytes <= 0
+	MaxBodyBytes      int64 // requests with larger body are refused with 413, no limit if MaxBodyBytes <= 0
+	TLS               *TLSOption
+	HTTP2             *HTTP2Option // go default http/2 over tls if it is nil
+}
+
+// HTTP2Option http/2 options, zero values mean go defaults
+// it is built on http.Server.HTTP2 and http.Server.Protocols, go 1.24 or later is required
+type HTTP2Option struct {
+	MaxConcurrentStreams          int
+	MaxReadFrameSize              int // between 16KB and 16MB
+	MaxReceiveBufferPerConnection int
+	MaxReceiveBufferPerStream     int
+	PingTimeout                   time.Duration
+	WriteByteTimeout              time.Duration
+
+	// H2C serve http/2 without tls (prior knowledge) on the same port as http/1.1,
+	// use it only behind a trusted network or proxy
+	H2C bool
+
+	// DisableHTTP1 serve http/2 only
+	DisableHTTP1 bool
+}
+
+// TLSOption tls options
+type TLSOption struct {
+	CertFile     string
+	KeyFile      string
+	MinVersion   uint16   // tls.VersionTLS12 if MinVersion is 0
+	CipherSuites []uint16 // go default if empty
+
+	// ClientCAFile pem file of CAs to verify client certificates, mutual tls is on if it is set
+	ClientCAFile string
+	// ClientAuth tls.RequireAndVerifyClientCert if it is not set and ClientCAFile is set
+	ClientAuth tls.ClientAuthType
+
+	// ReloadInterval check certificate files every ReloadInterval and reload them if they are modified,
+	// no reload if ReloadInterval <= 0
+	ReloadInterval time.Duration
+}
+
+// configureHTTP2 configure http/2 and protocols of s
+func configureHTTP2(s *http.Server, option *HTTP2Option) {
+	s.HTTP2 = &http.HTTP2Config{
+		MaxConcurrentStreams:          option.MaxConcurrentStreams,
+		MaxReadFrameSize:              option.MaxReadFrameSize,
+		MaxReceiveBufferPerConnection: option.MaxReceiveBufferPerConnection,
+		MaxReceiveBufferPerStream:     option.MaxReceiveBufferPerStream,
+		PingTimeout:                   option.PingTimeout,
+		WriteByteTimeout:              option.WriteByteTimeout,
+	}
+	p := new(http.Protocols)
+	p.SetHTTP1(!option.DisableHTTP1)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(option.H2C)
+	s.Protocols = p
+}
+
+func (a *HTTPServer) tlsConfig(certFile, keyFile string) (*tls.Config, error) {
+	option := a.tlsOption
+	if option == nil {
+		option = &TLSOption{}
+	}
+	if certFile == "" {
+		certFile, keyFile = option.CertFile, option.KeyFile
+	}
+	reloaders := make([]*certReloader, 0)
+	for _, vh := range a.defaultHandler.hosts {
+		if vh.cert != nil {
+			reloaders = append(reloaders, vh.cert)
+		}
+	}
+	if (certFile == "" || keyFile == "") && len(reloaders) == 0 {
+		return nil, errors.New("httpserver: certificate or key file is missing")
+	}
+	c := &tls.Config{
+		MinVersion:   option.MinVersion,
+		CipherSuites: option.CipherSuites,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if a.s.Protocols != nil && !a.s.Protocols.HTTP1() {
+		c.NextProtos = []string{"h2"}
+	}
+	if c.MinVersion == 0 {
+		c.MinVersion = tls.VersionTLS12
+	}
+	if option.ClientCAFile != "" {
+		b, err := ioutil.ReadFile(option.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.New("httpserver: no certificate found in " + option.ClientCAFile)
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = option.ClientAuth
+		if c.ClientAuth == tls.NoClientCert {
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	fallback := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return nil, errors.New("httpserver: no certificate for server name")
+	}
+	if certFile != "" && keyFile != "" {
+		r, err := newCertReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		reloaders = append(reloaders, r)
+		fallback = r.get
+	}
+	if option.ReloadInterval > 0 {
+		for _, r := range reloaders {
+			go r.watch(option.ReloadInterval, a.done, a.defaultHandler.logf)
+		}
+	}
+	// certificates of virtual hosts are selected by SNI
+	c.GetCertificate = a.defaultHandler.hostCertificate(fallback)
+	return c, nil
+}
+
+// certReloader keep a certificate loaded from files up to date
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mutex    *sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, mutex: new(sync.RWMutex)}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (a *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(a.certFile, a.keyFile)
+	if err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.cert = &cert
+	a.modTime = a.lastModified()
+	return nil
+}
+
+func (a *certReloader) lastModified() time.Time {
+	var t time.Time
+	for _, f := range []string{a.certFile, a.keyFile} {
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t
+}
+
+func (a *certReloader) watch(interval time.Duration, done chan struct{}, logf func(format string, v ...interface{})) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			a.mutex.RLock()
+			modTime := a.modTime
+			a.mutex.RUnlock()
+			if a.lastModified().After(modTime) {
+				if err := a.load(); err != nil {
+					logf("reload certificate %s error: %s", a.certFile, err.Error())
+				}
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (a *certReloader) get(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.cert, nil
+}
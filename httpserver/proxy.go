@@ -0,0 +1,397 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// RoundRobin pick upstreams in turn
+	RoundRobin = iota
+
+	// LeastConnections pick the upstream which has fewest active requests
+	LeastConnections
+)
+
+// hop-by-hop headers, they are not forwarded, nor are headers named in Connection
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// ErrNoUpstream no healthy upstream to forward request to
+var ErrNoUpstream = errors.New("proxy: no healthy upstream")
+
+// ProxyOption reverse proxy options
+type ProxyOption struct {
+	Balance int // RoundRobin or LeastConnections
+
+	// Retries retry idempotent requests on another upstream if connection fails or 502, 503, 504 is returned
+	Retries int
+	Timeout time.Duration // timeout of each attempt, 30 seconds if Timeout <= 0
+
+	// HealthPath path to check upstreams with GET, upstreams not returning 2xx are skipped.
+	// no active health check if it is empty
+	HealthPath     string
+	HealthInterval time.Duration // 10 seconds if HealthInterval <= 0
+
+	// StripPrefix removed from url path before forwarding
+	StripPrefix string
+	// Rewrite template of forwarded url path, {param} is replaced by dynamic value of the pattern
+	// e.g. pattern is /users/{id} and Rewrite is /api/v2/user/{id}
+	Rewrite string
+
+	RequestHeaders       map[string]string // set on forwarded requests
+	RemoveRequestHeaders []string
+	ResponseHeaders      map[string]string // set on responses
+
+	// MaxBodySize max bytes of request body, client gets 413 if it is exceeded, default 10MB
+	MaxBodySize int64
+	// MaxResponseSize max bytes of upstream response body, client gets 502 if it is exceeded, default 10MB
+	MaxResponseSize int64
+}
+
+const defaultProxyBodySize = 10 << 20
+
+type upstream struct {
+	url       *url.URL
+	active    int64
+	unhealthy int32
+}
+
+type proxy struct {
+	upstreams       []*upstream
+	next            uint64
+	option          *ProxyOption
+	client          *http.Client
+	maxBodySize     int64
+	maxResponseSize int64
+}
+
+// Proxy forward requests matching pattern to targets, e.g. http://10.0.0.1:8080
+// a pattern without {param} forwards all urls under it.
+// client gets a bare 502 if upstreams fail, or 503 if no upstream is healthy
+// e.g.
+//     s.Proxy("/legacy", []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}, &httpserver.ProxyOption{
+//         StripPrefix: "/legacy",
+//         Retries:     1,
+//         HealthPath:  "/ping",
+//     })
+//     s.Proxy("/users/{id}", []string{"http://10.0.0.3:8080"}, &httpserver.ProxyOption{Rewrite: "/api/user/{id}"})
+func (a *HTTPServer) Proxy(pattern string, targets []string, option *ProxyOption) error {
+	return a.defaultHandler.proxy(pattern, targets, option, a.done)
+}
+
+// proxy health check stops when done is closed
+func (a *handler) proxy(pattern string, targets []string, option *ProxyOption, done chan struct{}) error {
+	if option == nil {
+		option = &ProxyOption{}
+	}
+	p := &proxy{option: option, maxBodySize: option.MaxBodySize, maxResponseSize: option.MaxResponseSize}
+	if p.maxBodySize <= 0 {
+		p.maxBodySize = defaultProxyBodySize
+	}
+	if p.maxResponseSize <= 0 {
+		p.maxResponseSize = defaultProxyBodySize
+	}
+	for _, t := range targets {
+		u, err := url.Parse(t)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return errors.New("proxy: invalid target " + t)
+		}
+		p.upstreams = append(p.upstreams, &upstream{url: u})
+	}
+	if len(p.upstreams) == 0 {
+		return ErrNoUpstream
+	}
+	timeout := option.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	p.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			MaxIdleConnsPerHost: 32,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if option.HealthPath != "" {
+		go p.healthCheck(done)
+	}
+	if strings.Contains(pattern, "{") {
+		a.dynamicRoute(pattern, p.serve)
+	} else {
+		a.prefixRoute(pattern, p.serve)
+	}
+	return nil
+}
+
+func (a *proxy) pick(tried map[*upstream]bool) *upstream {
+	var best *upstream
+	n := len(a.upstreams)
+	start := int(atomic.AddUint64(&a.next, 1) % uint64(n))
+	for i := 0; i < n; i++ {
+		u := a.upstreams[(start+i)%n]
+		if tried[u] || atomic.LoadInt32(&u.unhealthy) == 1 {
+			continue
+		}
+		if a.option.Balance != LeastConnections {
+			return u
+		}
+		if best == nil || atomic.LoadInt64(&u.active) < atomic.LoadInt64(&best.active) {
+			best = u
+		}
+	}
+	return best
+}
+
+func (a *proxy) healthCheck(done chan struct{}) {
+	interval := a.option.HealthInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for _, u := range a.upstreams {
+			go a.checkUpstream(u)
+		}
+		select {
+		case <-t.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (a *proxy) checkUpstream(u *upstream) {
+	rsp, err := a.client.Get(strings.TrimRight(u.url.String(), "/") + singleJoiningSlash("", a.option.HealthPath))
+	healthy := err == nil && rsp.StatusCode >= 200 && rsp.StatusCode < 300
+	if err == nil {
+		ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+	}
+	if healthy {
+		atomic.StoreInt32(&u.unhealthy, 0)
+	} else {
+		atomic.StoreInt32(&u.unhealthy, 1)
+	}
+}
+
+func (a *proxy) path(req *Request) string {
+	if a.option.Rewrite != "" {
+		p := a.option.Rewrite
+		for k, v := range req.dynamicParams {
+			p = strings.Replace(p, "{"+k+"}", url.PathEscape(v), -1)
+		}
+		return p
+	}
+	p := req.URL.EscapedPath()
+	if a.option.StripPrefix != "" {
+		p = strings.TrimPrefix(p, strings.TrimRight(a.option.StripPrefix, "/"))
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+	}
+	return p
+}
+
+func (a *proxy) serve(rsp *Response, req *Request) (uint, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(http.MaxBytesReader(nil, req.Body, a.maxBodySize))
+		req.Body.Close()
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				rsp.WriteStatusCode(http.StatusRequestEntityTooLarge)
+				return http.StatusRequestEntityTooLarge, nil
+			}
+			return http.StatusBadRequest, err
+		}
+	}
+	retries := 0
+	if idempotent(req.Method) {
+		retries = a.option.Retries
+	}
+	tried := make(map[*upstream]bool)
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		u := a.pick(tried)
+		if u == nil {
+			break
+		}
+		tried[u] = true
+		r, err := a.forward(u, req, body)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if attempt < retries && (r.code == http.StatusBadGateway || r.code == http.StatusServiceUnavailable || r.code == http.StatusGatewayTimeout) {
+			lastErr = errors.New("proxy: upstream " + u.url.Host + " returned " + http.StatusText(r.code))
+			continue
+		}
+		a.write(rsp, r)
+		return uint(r.code), nil
+	}
+	// errors of upstreams tell addresses of upstreams, do not show them to client
+	code := http.StatusBadGateway
+	if lastErr == nil {
+		code = http.StatusServiceUnavailable
+	}
+	rsp.Reset()
+	rsp.WriteStatusCode(code)
+	return uint(code), nil
+}
+
+type upstreamResponse struct {
+	code   int
+	header http.Header
+	body   []byte
+}
+
+func (a *proxy) forward(u *upstream, req *Request, body []byte) (*upstreamResponse, error) {
+	atomic.AddInt64(&u.active, 1)
+	defer atomic.AddInt64(&u.active, -1)
+
+	s := strings.TrimRight(u.url.Scheme+"://"+u.url.Host+u.url.EscapedPath(), "/") + singleJoiningSlash("", a.path(req))
+	if req.URL.RawQuery != "" {
+		s += "?" + req.URL.RawQuery
+	}
+	out, err := http.NewRequest(req.Method, s, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	out = out.WithContext(req.Context())
+	for k, vs := range req.Header {
+		out.Header[k] = append([]string(nil), vs...)
+	}
+	removeHopHeaders(out.Header)
+	for _, h := range a.option.RemoveRequestHeaders {
+		out.Header.Del(h)
+	}
+	for k, v := range a.option.RequestHeaders {
+		out.Header.Set(k, v)
+	}
+	out.Host = req.Host
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+			host = prior + ", " + host
+		}
+		out.Header.Set("X-Forwarded-For", host)
+	}
+	out.Header.Set("X-Forwarded-Host", req.Host)
+	if req.TLS != nil {
+		out.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		out.Header.Set("X-Forwarded-Proto", "http")
+	}
+
+	r, err := a.client.Do(out)
+	if err != nil {
+		if a.option.HealthPath != "" {
+			atomic.StoreInt32(&u.unhealthy, 1) // until next health check
+		}
+		return nil, err
+	}
+	defer r.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, a.maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > a.maxResponseSize {
+		return nil, errors.New("proxy: response of upstream " + u.url.Host + " is too large")
+	}
+	return &upstreamResponse{code: r.StatusCode, header: r.Header, body: b}, nil
+}
+
+func (a *proxy) write(rsp *Response, r *upstreamResponse) {
+	removeHopHeaders(r.header)
+	h := rsp.Header()
+	for k, vs := range r.header {
+		h[k] = append([]string(nil), vs...)
+	}
+	h.Del("Content-Length")
+	for k, v := range a.option.ResponseHeaders {
+		h.Set(k, v)
+	}
+	rsp.Reset()
+	rsp.WriteStatusCode(r.code)
+	if len(r.body) > 0 {
+		rsp.Write(r.body)
+	}
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, v := range h["Connection"] {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				h.Del(k)
+			}
+		}
+	}
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
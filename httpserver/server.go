@@ -27,17 +27,35 @@ package httpserver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
 	"regexp"
+	"runtime/debug"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/log"
 )
 
 // Filter handler is surrounded by filter
 // `Before` works before handler function
 // `After` works after handler function
 // handler function will not work if any `Before` function returns false
+//
+// all filters matching a urlpath are applied, ordered by priority and then by registration order,
+// `Before` functions run in that order and `After` functions run in reverse order,
+// `After` runs only for filters whose `Before` returned true.
+// a `Before` returning false can write a response with rsp.WriteStatusCode and rsp.Write, it is sent as is
 type Filter interface {
 	Before(rsp *Response, r *Request) bool
 	After(rsp *Response, r *Request)
@@ -56,6 +74,34 @@ type Filter interface {
 type Request struct {
 	*http.Request
 	dynamicParams map[string]string
+	values        map[string]interface{}
+	pattern       string
+	upload        *UploadOption
+	uploadErr     error
+	mediaType     string
+	version       string
+}
+
+// Pattern get route pattern matched by request, e.g. /a/{id}
+// empty if no route matched
+func (a *Request) Pattern() string {
+	return a.pattern
+}
+
+// Set store a request-scoped value, it can be read by following filters and handler function
+func (a *Request) Set(k string, v interface{}) {
+	if a.values == nil {
+		a.values = make(map[string]interface{})
+	}
+	a.values[k] = v
+}
+
+// Get get a request-scoped value
+func (a *Request) Get(k string) interface{} {
+	if v, c := a.values[k]; c {
+		return v
+	}
+	return nil
 }
 
 // GetDynamicParam get dynamic value in dynamic url
@@ -75,6 +121,27 @@ type Response struct {
 	writeCode    bool
 	returnedCode uint
 	err          error
+	detached     bool // response has been written by other means, e.g. websocket, sse
+	wroteHeader  bool // header has been sent by WriteHeader
+	trailers     map[string]string
+}
+
+// Push initiate a http/2 server push of target, e.g. /static/app.js
+// http.ErrNotSupported is returned if client does not support push or connection is not http/2
+func (a *Response) Push(target string, opts *http.PushOptions) error {
+	if p, ok := a.rw.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// SetTrailer set a trailer sent after body
+// trailers are declared in header when response is written, call SetTrailer before rsp.WriteHeader
+func (a *Response) SetTrailer(k, v string) {
+	if a.trailers == nil {
+		a.trailers = make(map[string]string)
+	}
+	a.trailers[k] = v
 }
 
 // Write write bytes
@@ -95,7 +162,15 @@ func (a *Response) Header() http.Header {
 // just like what net/http.ResponseWrite.WriteHeaer(int) does
 func (a *Response) WriteHeader(statusCode int) {
 	a.code = statusCode
-	a.rw.WriteHeader(statusCode)
+	if !a.detached {
+		a.wroteHeader = true
+		a.rw.WriteHeader(statusCode)
+	}
+}
+
+// HeaderWritten whether header has been sent by WriteHeader, changes to header do not work then
+func (a *Response) HeaderWritten() bool {
+	return a.wroteHeader
 }
 
 // WriteStatusCode write status code
@@ -104,25 +179,118 @@ func (a *Response) WriteStatusCode(statusCode int) {
 	a.writeCode = true
 }
 
+// StatusCode get status code you have writen, 200 if you have not writen any
+func (a *Response) StatusCode() int {
+	if a.code == 0 {
+		return http.StatusOK
+	}
+	return a.code
+}
+
 // Bytes get bytes you have writen
 func (a *Response) Bytes() []byte {
 	return a.b
 }
 
+// Reset discard bytes you have writen
+func (a *Response) Reset() {
+	a.b = nil
+	a.writeBytes = false
+}
+
+// ReturnedCode get code returned by handler function
+func (a *Response) ReturnedCode() uint {
+	return a.returnedCode
+}
+
+// Err get error returned by handler function
+func (a *Response) Err() error {
+	return a.err
+}
+
+// Detached whether response has been taken over by other means, e.g. websocket or sse
+func (a *Response) Detached() bool {
+	return a.detached
+}
+
 func defaultNotFound(rsp *Response, req *Request) (uint, error) {
 	rsp.WriteStatusCode(404)
 	rsp.Write([]byte(`page not found`))
 	return 404, nil
 }
 
+// Problem problem details, see RFC 7807
+// return a *Problem from handler function to send its Detail to client,
+// e.g. return 400, &httpserver.Problem{Detail: "name is required"}
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func (a *Problem) Error() string {
+	if a.Detail != "" {
+		return a.Detail
+	}
+	return a.Title
+}
+
+// DefaultErrorHandler render error as application/problem+json
+// status code is the code returned by handler function if it is a valid http status code, otherwise 500
+// only a *Problem is sent to client as is, other errors may contain internal details,
+// they are logged and client gets status text only
+func DefaultErrorHandler(rsp *Response, req *Request, code uint, err error) {
+	status := http.StatusInternalServerError
+	if code >= 400 && code <= 599 {
+		status = int(code)
+	}
+	p := &Problem{}
+	if e, ok := err.(*Problem); ok {
+		*p = *e
+	}
+	if p.Status == 0 {
+		p.Status = status
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = req.URL.Path
+	}
+	b, _ := json.Marshal(p)
+	rsp.Reset()
+	rsp.Header().Set("Content-Type", "application/problem+json")
+	rsp.WriteStatusCode(p.Status)
+	rsp.Write(b)
+}
+
+// PanicError error recovered from a panic in filters or handler functions
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (a *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", a.Value)
+}
+
 type handler struct {
-	notFound            func(rsp *Response, req *Request) (uint, error)
-	plainFilters        map[string][]Filter
-	regexFilters        map[string][]Filter
-	sortedFilterPattern []string // regex string
-	sortedFilterRegex   []*regexp.Regexp
-	filterRegexPattern  map[string]string   // regex string -> raw pattern
-	filterPatternParams map[string][]string // raw pattern -> params
+	notFound     func(rsp *Response, req *Request) (uint, error)
+	errorHandler func(rsp *Response, req *Request, code uint, err error)
+	logger       *log.Logger
+	maxBodyBytes int64
+	metrics      *httpMetrics
+	uploads      map[string]*UploadOption // route pattern -> option
+	variants     map[string][]*variant    // route pattern -> variants registered with constraints
+	versioning   *VersionOption
+	docs         map[string][]*RouteDoc // route pattern -> openapi metadata
+	filters      []*filterEntry         // sorted by priority and registration order after prepare
+	hosts        []*VirtualHost
 
 	plainHandlers        map[string]func(rsp *Response, req *Request) (uint, error)
 	regexHandlers        map[string]func(rsp *Response, req *Request) (uint, error) // raw pattern -> func
@@ -130,15 +298,22 @@ type handler struct {
 	sortedHandlerRegex   []*regexp.Regexp
 	handlerRegexPattern  map[string]string   // regex string -> raw pattern
 	handlerPatternParams map[string][]string // raw pattern -> params
+
+	prefixHandlers map[string]func(rsp *Response, req *Request) (uint, error) // prefix -> func
+	sortedPrefix   []string                                                   // longest first
 }
 
 func newHandler() *handler {
 	return &handler{
 		notFound:      defaultNotFound,
-		plainFilters:  make(map[string][]Filter),
-		regexFilters:  make(map[string][]Filter),
+		errorHandler:  DefaultErrorHandler,
+		uploads:       make(map[string]*UploadOption),
+		variants:      make(map[string][]*variant),
+		docs:          make(map[string][]*RouteDoc),
 		plainHandlers: make(map[string]func(rsp *Response, req *Request) (uint, error)),
 		regexHandlers: make(map[string]func(rsp *Response, req *Request) (uint, error)),
+
+		prefixHandlers: make(map[string]func(rsp *Response, req *Request) (uint, error)),
 	}
 }
 
@@ -173,38 +348,44 @@ func (a *handler) preparaHandlers() {
 }
 
 func (a *handler) prepareFilters() {
-	if len(a.regexFilters) <= 0 {
-		return
-	}
 	preg := regexp.MustCompile(`({\w+})`)
-	a.filterPatternParams = make(map[string][]string, len(a.regexFilters))
-	a.filterRegexPattern = make(map[string]string, len(a.regexFilters))
-	a.sortedFilterPattern = make([]string, 0, len(a.regexFilters))
-
-	for rawPattern := range a.regexFilters {
-		params := preg.FindAllString(rawPattern, -1)
-		if params != nil && len(params) > 0 {
-			for i := range params {
-				params[i] = params[i][1 : len(params[i])-1]
-			}
-			a.filterPatternParams[rawPattern] = params
-			realRegex := "^" + preg.ReplaceAllString(rawPattern, `([^/]+)`) + "$"
-			a.filterRegexPattern[realRegex] = rawPattern
-			a.sortedFilterPattern = append(a.sortedFilterPattern, realRegex)
-		} else {
-			a.plainFilters[rawPattern] = a.regexFilters[rawPattern]
+	for _, e := range a.filters {
+		if !e.dynamic || e.regex != nil {
+			continue
+		}
+		params := preg.FindAllString(e.pattern, -1)
+		if len(params) <= 0 {
+			continue
+		}
+		for i := range params {
+			params[i] = params[i][1 : len(params[i])-1]
 		}
+		e.params = params
+		e.regex = regexp.MustCompile("^" + preg.ReplaceAllString(e.pattern, `([^/]+)`) + "$")
 	}
-	quicksort(a.sortedFilterPattern)
-	a.sortedFilterRegex = make([]*regexp.Regexp, 0, len(a.sortedFilterPattern))
-	for _, realRegex := range a.sortedFilterPattern {
-		a.sortedFilterRegex = append(a.sortedFilterRegex, regexp.MustCompile(realRegex))
+	sort.SliceStable(a.filters, func(i, j int) bool {
+		if a.filters[i].priority != a.filters[j].priority {
+			return a.filters[i].priority < a.filters[j].priority
+		}
+		return a.filters[i].seq < a.filters[j].seq
+	})
+}
+
+func (a *handler) preparePrefixHandlers() {
+	a.sortedPrefix = make([]string, 0, len(a.prefixHandlers))
+	for prefix := range a.prefixHandlers {
+		a.sortedPrefix = append(a.sortedPrefix, prefix)
 	}
+	sort.Slice(a.sortedPrefix, func(i, j int) bool {
+		return len(a.sortedPrefix[i]) > len(a.sortedPrefix[j])
+	})
 }
 
 func (a *handler) prepare() {
 	a.prepareFilters()
 	a.preparaHandlers()
+	a.preparePrefixHandlers()
+	a.prepareHosts()
 }
 
 func (a *handler) route(path string, f func(rsp *Response, req *Request) (uint, error)) {
@@ -215,26 +396,30 @@ func (a *handler) dynamicRoute(pattern string, f func(rsp *Response, req *Reques
 	a.regexHandlers[pattern] = f
 }
 
-func (a *handler) filter(path string, f Filter) {
-	var fs []Filter
-	var c bool
-	if fs, c = a.plainFilters[path]; !c {
-		fs = make([]Filter, 0)
+func (a *handler) prefixRoute(prefix string, f func(rsp *Response, req *Request) (uint, error)) {
+	if prefix != "/" {
+		prefix = strings.TrimRight(prefix, "/")
 	}
-	fs = append(fs, f)
-	a.plainFilters[path] = fs
+	a.prefixHandlers[prefix] = f
+}
 
+// filterEntry a registered filter
+type filterEntry struct {
+	f        Filter
+	pattern  string
+	dynamic  bool
+	priority int
+	seq      int            // registration order
+	regex    *regexp.Regexp // nil if pattern has no params
+	params   []string
 }
 
-func (a *handler) dynamicFilter(pattern string, f Filter) {
-	var fs []Filter
-	var c bool
-	if fs, c = a.regexFilters[pattern]; !c {
-		fs = make([]Filter, 0)
-	}
-	fs = append(fs, f)
-	a.regexFilters[pattern] = fs
+func (a *handler) filter(path string, f Filter, priority int) {
+	a.filters = append(a.filters, &filterEntry{f: f, pattern: path, priority: priority, seq: len(a.filters)})
+}
 
+func (a *handler) dynamicFilter(pattern string, f Filter, priority int) {
+	a.filters = append(a.filters, &filterEntry{f: f, pattern: pattern, dynamic: true, priority: priority, seq: len(a.filters)})
 }
 
 func (a *handler) matchPlainHandler(url string) func(rsp *Response, req *Request) (uint, error) {
@@ -256,95 +441,143 @@ func (a *handler) matchRegexpHandler(url string) (*regexp.Regexp, []string, func
 	return nil, nil, nil
 }
 
-func (a *handler) matchPlainFilter(url string) []Filter {
-	for k, f := range a.plainFilters {
-		if k == url {
-			return f
+// matchPrefixHandler returns pattern and handler function, pattern is prefix ends with "/*"
+func (a *handler) matchPrefixHandler(url string) (string, func(rsp *Response, req *Request) (uint, error)) {
+	for _, prefix := range a.sortedPrefix {
+		if prefix == "/" || url == prefix || strings.HasPrefix(url, prefix+"/") {
+			return strings.TrimRight(prefix, "/") + "/*", a.prefixHandlers[prefix]
 		}
 	}
-	return nil
+	return "", nil
 }
 
-func (a *handler) matchRegexpFilter(url string) (*regexp.Regexp, []string, []Filter) {
-	for i, regex := range a.sortedFilterRegex {
-		if regex.MatchString(url) {
-			pattern := a.filterRegexPattern[a.sortedFilterPattern[i]]
-			return regex, a.filterPatternParams[pattern], a.regexFilters[pattern]
+// matchFilters get all filters matching url in order
+func (a *handler) matchFilters(url string) []*filterEntry {
+	matched := make([]*filterEntry, 0)
+	for _, e := range a.filters {
+		if e.regex != nil && e.regex.MatchString(url) || e.regex == nil && e.pattern == url {
+			matched = append(matched, e)
 		}
 	}
-
-	return nil, nil, nil
+	return matched
 }
 
 func (a *handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if vh, params := a.matchHost(r.Host, false); vh != nil {
+		vh.h.serve(rw, r, params)
+		return
+	}
+	a.serve(rw, r, nil)
+}
+
+// serve route request, params captured from host are put into dynamic params
+func (a *handler) serve(rw http.ResponseWriter, r *http.Request, hostParams map[string]string) {
 	url, err := url.QueryUnescape(r.URL.Path)
 	if err != nil {
 		rw.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	filters := a.matchPlainFilter(url)
-	var filterParams []string
-	var filterRegex *regexp.Regexp
-	if filters == nil || len(filters) <= 0 {
-		filterRegex, filterParams, filters = a.matchRegexpFilter(url)
-	}
+	filters := a.matchFilters(url)
 
 	h := a.matchPlainHandler(url)
+	pattern := url
 	var handlerParams []string
 	var handlerRegex *regexp.Regexp
 	if h == nil {
 		handlerRegex, handlerParams, h = a.matchRegexpHandler(url)
+		if handlerRegex != nil {
+			pattern = a.handlerRegexPattern[handlerRegex.String()]
+		}
+	}
+	if h == nil {
+		pattern, h = a.matchPrefixHandler(url)
 	}
 
-	req := &Request{r, make(map[string]string)}
+	req := &Request{Request: r, dynamicParams: make(map[string]string), pattern: pattern, upload: a.uploads[pattern]}
 	rsp := &Response{rw: rw}
+	defer req.cleanup()
+	for k, v := range hostParams {
+		req.dynamicParams[k] = v
+	}
 
-	if filters != nil && len(filters) > 0 {
-		if filterRegex != nil {
-			tmp := filterRegex.FindStringSubmatch(url)
-			values := make([]string, 0)
-			if len(tmp) > 1 {
-				for i := 1; i < len(tmp); i++ {
-					values = append(values, tmp[i])
-				}
-			}
-			for i := range values {
-				req.dynamicParams[filterParams[i]] = values[i]
-			}
+	if a.metrics != nil {
+		start := time.Now()
+		a.metrics.begin(req)
+		defer a.metrics.end(req, rsp, start)
+	}
+
+	if a.maxBodyBytes > 0 {
+		if r.ContentLength > a.maxBodyBytes {
+			rsp.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
 		}
+		r.Body = http.MaxBytesReader(rw, r.Body, a.maxBodyBytes)
+	}
 
-		for _, f := range filters {
-			if !f.Before(rsp, req) {
-				return
+	for _, e := range filters {
+		if e.regex == nil {
+			continue
+		}
+		values := e.regex.FindStringSubmatch(url)
+		for i := 1; i < len(values); i++ {
+			if _, c := req.dynamicParams[e.params[i-1]]; !c {
+				req.dynamicParams[e.params[i-1]] = values[i]
 			}
 		}
 	}
 
-	if h != nil {
-		if handlerRegex != nil {
-			tmp := handlerRegex.FindStringSubmatch(url)
-			values := make([]string, 0)
-			if len(tmp) > 1 {
-				for i := 1; i < len(tmp); i++ {
-					values = append(values, tmp[i])
+	passed := 0
+	for _, e := range filters {
+		pass := false
+		if panicked := a.safeCall(rsp, req, func() { pass = e.f.Before(rsp, req) }); panicked || !pass {
+			break
+		}
+		passed++
+	}
+
+	if passed == len(filters) { // handler function will not work if any `Before` fails or panics, `After` of passed filters still works
+		if h != nil {
+			if handlerRegex != nil {
+				tmp := handlerRegex.FindStringSubmatch(url)
+				values := make([]string, 0)
+				if len(tmp) > 1 {
+					for i := 1; i < len(tmp); i++ {
+						values = append(values, tmp[i])
+					}
 				}
-			}
-			for i := range values {
-				if _, c := req.dynamicParams[handlerParams[i]]; !c {
-					req.dynamicParams[handlerParams[i]] = values[i]
+				for i := range values {
+					if _, c := req.dynamicParams[handlerParams[i]]; !c {
+						req.dynamicParams[handlerParams[i]] = values[i]
+					}
 				}
 			}
+			a.safeCall(rsp, req, func() { rsp.returnedCode, rsp.err = h(rsp, req) })
+		} else {
+			a.safeCall(rsp, req, func() { rsp.returnedCode, rsp.err = a.notFound(rsp, req) })
 		}
-		rsp.returnedCode, rsp.err = h(rsp, req)
-	} else {
-		rsp.returnedCode, rsp.err = a.notFound(rsp, req)
 	}
 
-	if filters != nil && len(filters) > 0 {
-		for _, f := range filters {
-			f.After(rsp, req)
+	if rsp.err != nil {
+		if _, c := rsp.err.(*PanicError); !c && !negotiationMiss(rsp.err) {
+			a.logf("%s %s returned code: %d, error: %s", r.Method, url, rsp.returnedCode, rsp.err.Error())
 		}
+		if a.errorHandler != nil {
+			a.safeCall(rsp, req, func() { a.errorHandler(rsp, req, rsp.returnedCode, rsp.err) })
+		}
+	}
+
+	for i := passed - 1; i >= 0; i-- {
+		f := filters[i].f
+		a.safeCall(rsp, req, func() { f.After(rsp, req) })
+	}
+
+	if rsp.detached {
+		return
+	}
+
+	for k := range rsp.trailers {
+		rsp.rw.Header().Add("Trailer", k)
 	}
 
 	if rsp.writeCode {
@@ -355,6 +588,41 @@ func (a *handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		rsp.rw.Write(rsp.b)
 	}
 
+	for k, v := range rsp.trailers {
+		rsp.rw.Header().Set(http.TrailerPrefix+k, v)
+	}
+
+}
+
+// safeCall call f and recover from panic
+// returns true if f panics, then response is reset to 500
+// and returned code and error are set to 500 and *PanicError if handler function has not returned an error
+func (a *handler) safeCall(rsp *Response, req *Request, f func()) (panicked bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler { // net/http aborts response silently
+				panic(v)
+			}
+			panicked = true
+			e := &PanicError{Value: v, Stack: debug.Stack()}
+			a.logf("%s %s panic: %v\n%s", req.Method, req.URL.Path, v, e.Stack)
+			if rsp.err == nil {
+				rsp.returnedCode, rsp.err = http.StatusInternalServerError, e
+			}
+			rsp.Reset()
+			rsp.WriteStatusCode(http.StatusInternalServerError)
+		}
+	}()
+	f()
+	return false
+}
+
+func (a *handler) logf(format string, v ...interface{}) {
+	if a.logger != nil {
+		a.logger.Error(format, v...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
 }
 
 // NewHTTPServer return a httpserver which will bind on `port`
@@ -365,7 +633,35 @@ func NewHTTPServer(port int) *HTTPServer {
 		Addr:    ":" + strconv.Itoa(port),
 		Handler: http.DefaultServeMux,
 	}
-	return &HTTPServer{s: s, defaultHandler: h, block: true}
+	return &HTTPServer{s: s, defaultHandler: h, block: true, done: make(chan struct{})}
+}
+
+// NewHTTPServerWithOption return a httpserver configured by option
+// unlike NewHTTPServer, it does not register on http.DefaultServeMux,
+// so that more than one server can be created in a process
+func NewHTTPServerWithOption(option *ServerOption) *HTTPServer {
+	if option == nil {
+		option = &ServerOption{}
+	}
+	h := newHandler()
+	h.maxBodyBytes = option.MaxBodyBytes
+	addr := option.Addr
+	if addr == "" {
+		addr = ":" + strconv.Itoa(option.Port)
+	}
+	s := &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadTimeout:       option.ReadTimeout,
+		ReadHeaderTimeout: option.ReadHeaderTimeout,
+		WriteTimeout:      option.WriteTimeout,
+		IdleTimeout:       option.IdleTimeout,
+		MaxHeaderBytes:    option.MaxHeaderBytes,
+	}
+	if option.HTTP2 != nil {
+		configureHTTP2(s, option.HTTP2)
+	}
+	return &HTTPServer{s: s, defaultHandler: h, block: true, done: make(chan struct{}), tlsOption: option.TLS}
 }
 
 // HTTPServer httpserver
@@ -373,8 +669,15 @@ type HTTPServer struct {
 	s              *http.Server
 	defaultHandler *handler
 	// handlers       map[string]*handler
-	block  bool
-	finish func(err error)
+	block     bool
+	finish    func(err error)
+	hooks     []func()
+	draining  int32 // 1 after shutdown begins
+	once      sync.Once
+	done      chan struct{} // closed after shutdown finished
+	tlsOption *TLSOption
+	checks    []*check
+	admin     *http.Server
 }
 
 // Route register a handler function with a static urlpath
@@ -409,12 +712,23 @@ func (a *HTTPServer) DynamicRoute(pattern string, f func(rsp *Response, req *Req
 //        return passornot
 //    }
 func (a *HTTPServer) DynamicFilter(pattern string, f Filter) {
-	a.defaultHandler.dynamicFilter(pattern, f)
+	a.defaultHandler.dynamicFilter(pattern, f, 0)
+}
+
+// DynamicFilterWithPriority register a dynamic filter with priority, filters of lower priority run `Before` earlier
+// DynamicFilter registers filters with priority 0
+func (a *HTTPServer) DynamicFilterWithPriority(pattern string, f Filter, priority int) {
+	a.defaultHandler.dynamicFilter(pattern, f, priority)
 }
 
 // Filter register a filter with as static urlpath
 func (a *HTTPServer) Filter(path string, f Filter) {
-	a.defaultHandler.filter(path, f)
+	a.defaultHandler.filter(path, f, 0)
+}
+
+// FilterWithPriority register a filter of a static urlpath with priority, see DynamicFilterWithPriority
+func (a *HTTPServer) FilterWithPriority(path string, f Filter, priority int) {
+	a.defaultHandler.filter(path, f, priority)
 }
 
 // NotFound set your 404 handler function
@@ -422,47 +736,171 @@ func (a *HTTPServer) NotFound(f func(rsp *Response, req *Request) (uint, error))
 	a.defaultHandler.notFound = f
 }
 
+// ErrorHandler set your error handler function
+// it will be called when handler function returns a non-nil error,
+// before `After` of filters. `DefaultErrorHandler` is used by default.
+// set nil to keep what handler function has writen
+func (a *HTTPServer) ErrorHandler(f func(rsp *Response, req *Request, code uint, err error)) {
+	a.defaultHandler.errorHandler = f
+}
+
+// Logger set logger of server
+// errors are printed to stderr if logger is not set
+func (a *HTTPServer) Logger(l *log.Logger) {
+	a.defaultHandler.logger = l
+}
+
 // ServeHTTP launch a http serve
 func (a *HTTPServer) ServeHTTP() {
-	a.defaultHandler.prepare()
-	if !a.block {
-		go func() {
-			a.doServeHTTP()
-		}()
-		return
-	}
-	a.doServeHTTP()
+	a.launch(a.s.ListenAndServe)
 }
 
 // ServeHTTPS launch a https serve
+// certFile and keyFile can be empty if they are set in TLSOption or virtual hosts have certificates
 func (a *HTTPServer) ServeHTTPS(certFile, keyFile string) {
+	a.launch(func() error {
+		c, err := a.tlsConfig(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		a.s.TLSConfig = c
+		return a.s.ListenAndServeTLS("", "")
+	})
+}
+
+// ServeListener launch a http serve on l
+// wrap l with tls.NewListener if you need https
+func (a *HTTPServer) ServeListener(l net.Listener) {
+	a.launch(func() error {
+		return a.s.Serve(l)
+	})
+}
+
+// ServeUnix launch a http serve on unix socket, stale socket file will be removed
+func (a *HTTPServer) ServeUnix(path string) {
+	a.launch(func() error {
+		os.Remove(path)
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return err
+		}
+		return a.s.Serve(l)
+	})
+}
+
+// Handler prepare routes and return server as http.Handler, e.g. to mount it into another mux or to test it in memory
+// routes registered after calling Handler are not served until Handler is called again
+func (a *HTTPServer) Handler() http.Handler {
+	a.defaultHandler.prepare()
+	return a.defaultHandler
+}
+
+func (a *HTTPServer) launch(serve func() error) {
 	a.defaultHandler.prepare()
 	if !a.block {
 		go func() {
-			a.doServeHTTPS(certFile, keyFile)
+			a.wait(serve)
 		}()
 		return
 	}
-	a.doServeHTTPS(certFile, keyFile)
+	a.wait(serve)
 }
 
-func (a *HTTPServer) doServeHTTP() {
-	err := a.s.ListenAndServe()
-	if err != nil {
+func (a *HTTPServer) wait(serve func() error) {
+	err := serve()
+	if err == http.ErrServerClosed {
+		<-a.done
+	} else if err != nil {
 		fmt.Printf("ListenAndServe error:%v\n", err.Error())
 	}
 }
 
-func (a *HTTPServer) doServeHTTPS(certFile, keyFile string) {
-	err := a.s.ListenAndServeTLS(certFile, keyFile)
-	if err != nil && err != http.ErrServerClosed {
-		fmt.Printf("ListenAndServe error:%v\n", err.Error())
+// Shutdown shutdown server and then call finish
+// it waits for all active connections to be idle
+func (a *HTTPServer) Shutdown() {
+	a.shutdown(context.Background())
+}
+
+// ShutdownWithTimeout shutdown server, wait at most d for active connections to be idle,
+// connections still active after d are closed.
+// shutdown hooks and finish are called after that
+// returns context.DeadlineExceeded if d is exceeded
+func (a *HTTPServer) ShutdownWithTimeout(d time.Duration) error {
+	if d <= 0 {
+		return a.shutdown(context.Background())
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return a.shutdown(ctx)
+}
+
+func (a *HTTPServer) shutdown(ctx context.Context) error {
+	var err error
+	a.once.Do(func() {
+		atomic.StoreInt32(&a.draining, 1)
+		err = a.s.Shutdown(ctx)
+		if err != nil {
+			a.s.Close()
+		}
+		if a.admin != nil {
+			a.admin.Close()
+		}
+		for _, f := range a.hooks {
+			f()
+		}
+		if a.finish != nil {
+			a.finish(err)
+		}
+		close(a.done)
+	})
+	return err
 }
 
-// Shutdown shutdown server and then call finish
-func (a *HTTPServer) Shutdown() {
-	a.finish(a.s.Shutdown(context.TODO()))
+// HandleSignals block until SIGTERM or SIGINT is received, then drain and shutdown server.
+// readiness turns to 503 at once, server keeps serving for delay so that load balancers can notice it,
+// then ShutdownWithTimeout(timeout) is called
+// e.g.
+//     s := httpserver.NewHTTPServer(9090)
+//     s.Readiness("/readyz")
+//     s.OnShutdown(func() { orm.Close() })
+//     go s.HandleSignals(5*time.Second, 30*time.Second)
+//     s.ServeHTTP()
+func (a *HTTPServer) HandleSignals(delay, timeout time.Duration) error {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(c)
+	sig := <-c
+	a.defaultHandler.logf("receive signal: %v, shutdown server", sig)
+	atomic.StoreInt32(&a.draining, 1)
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return a.ShutdownWithTimeout(timeout)
+}
+
+// OnShutdown register a hook which will be called after server has been shutdown, before finish.
+// e.g. close orm data sources or loggers
+func (a *HTTPServer) OnShutdown(f func()) {
+	a.hooks = append(a.hooks, f)
+}
+
+// Ready false once shutdown begins
+func (a *HTTPServer) Ready() bool {
+	return atomic.LoadInt32(&a.draining) == 0
+}
+
+// Readiness register a readiness probe
+// it responds 200 while server is serving, 503 once shutdown begins
+func (a *HTTPServer) Readiness(path string) {
+	a.Route(path, func(rsp *Response, req *Request) (uint, error) {
+		if !a.Ready() {
+			rsp.WriteStatusCode(http.StatusServiceUnavailable)
+			rsp.Write([]byte("draining"))
+			return http.StatusServiceUnavailable, nil
+		}
+		rsp.Write([]byte("ok"))
+		return http.StatusOK, nil
+	})
 }
 
 // Finish set your finish function
@@ -0,0 +1,151 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package session cookie based sessions for httpserver
+package session
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/tools/encrypt/ecb"
+)
+
+var (
+	// ErrInvalidCookie cookie is malformed or its signature does not match
+	ErrInvalidCookie = errors.New("session: invalid cookie")
+
+	// ErrExpiredCookie cookie is older than max age
+	ErrExpiredCookie = errors.New("session: expired cookie")
+)
+
+// Codec sign and optionally encrypt cookie values
+type Codec struct {
+	signKey    []byte
+	encryptKey string
+}
+
+// NewCodec return a codec
+// signKey is the HMAC-SHA256 key, encryptKey is an AES key of 16, 24 or 32 bytes, values are not encrypted if it is empty
+func NewCodec(signKey []byte, encryptKey string) (*Codec, error) {
+	if len(signKey) == 0 {
+		return nil, errors.New("session: sign key is empty")
+	}
+	if encryptKey != "" {
+		if _, err := aes.NewCipher([]byte(encryptKey)); err != nil {
+			return nil, err
+		}
+	}
+	return &Codec{signKey: signKey, encryptKey: encryptKey}, nil
+}
+
+// Encode sign and encrypt value of cookie name
+func (a *Codec) Encode(name, value string) string {
+	b := []byte(strconv.FormatInt(time.Now().Unix(), 10) + "|" + value)
+	if a.encryptKey != "" {
+		b = ecb.Encrypt(string(b), a.encryptKey)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + a.sign(name, payload)
+}
+
+// Decode verify and decrypt value of cookie name
+// maxAge is ignored if it is <= 0
+func (a *Codec) Decode(name, s string, maxAge time.Duration) (string, error) {
+	i := strings.LastIndex(s, ".")
+	if i < 0 {
+		return "", ErrInvalidCookie
+	}
+	payload := s[:i]
+	if !hmac.Equal([]byte(s[i+1:]), []byte(a.sign(name, payload))) {
+		return "", ErrInvalidCookie
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	if a.encryptKey != "" {
+		if b, err = decrypt(b, a.encryptKey); err != nil {
+			return "", err
+		}
+	}
+	parts := strings.SplitN(string(b), "|", 2)
+	if len(parts) != 2 {
+		return "", ErrInvalidCookie
+	}
+	t, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	if maxAge > 0 && time.Since(time.Unix(t, 0)) > maxAge {
+		return "", ErrExpiredCookie
+	}
+	return parts[1], nil
+}
+
+func (a *Codec) sign(name, payload string) string {
+	h := hmac.New(sha256.New, a.signKey)
+	h.Write([]byte(name + "|" + payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// decrypt ecb.Decrypt panics on malformed input
+func decrypt(b []byte, key string) (plain []byte, err error) {
+	if len(b) == 0 || len(b)%aes.BlockSize != 0 {
+		return nil, ErrInvalidCookie
+	}
+	defer func() {
+		if recover() != nil {
+			plain, err = nil, ErrInvalidCookie
+		}
+	}()
+	plain = ecb.Decrypt(b, key)
+	return plain, nil
+}
+
+// SetCookie set a signed (and encrypted) cookie
+func (a *Codec) SetCookie(rsp *httpserver.Response, c *http.Cookie) {
+	cc := *c
+	cc.Value = a.Encode(c.Name, c.Value)
+	if v := cc.String(); v != "" {
+		rsp.Header().Add("Set-Cookie", v)
+	}
+}
+
+// Cookie get value of a signed (and encrypted) cookie
+func (a *Codec) Cookie(req *httpserver.Request, name string, maxAge time.Duration) (string, error) {
+	c, err := req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return a.Decode(name, c.Value, maxAge)
+}
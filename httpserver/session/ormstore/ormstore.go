@@ -0,0 +1,100 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package ormstore session store backed by orm data sources
+//
+// table:
+//     CREATE TABLE T_SESSION (
+//         ID VARCHAR(64) PRIMARY KEY,
+//         DATA TEXT NOT NULL,
+//         EXPIRE_AT BIGINT NOT NULL,
+//         INDEX IDX_EXPIRE_AT (EXPIRE_AT)
+//     );
+package ormstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/orm"
+)
+
+// Store session store, values are saved as json, so numbers are loaded as float64
+type Store struct {
+	db    *sql.DB
+	table string
+}
+
+// NewStore return a store using table in a registered data source
+func NewStore(dataSource, table string) (*Store, error) {
+	db, err := orm.DB(dataSource)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, table: table}, nil
+}
+
+// Load load session
+func (a *Store) Load(id string) (map[string]interface{}, error) {
+	var data string
+	err := a.db.QueryRow("SELECT DATA FROM "+a.table+" WHERE ID = ? AND EXPIRE_AT > ?", id, time.Now().Unix()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	if err = json.Unmarshal([]byte(data), &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Save save session
+func (a *Store) Save(id string, values map[string]interface{}, ttl time.Duration) error {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = a.db.Exec("INSERT INTO "+a.table+" (ID, DATA, EXPIRE_AT) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE DATA = VALUES(DATA), EXPIRE_AT = VALUES(EXPIRE_AT)",
+		id, string(b), time.Now().Add(ttl).Unix())
+	return err
+}
+
+// Delete delete session
+func (a *Store) Delete(id string) error {
+	_, err := a.db.Exec("DELETE FROM "+a.table+" WHERE ID = ?", id)
+	return err
+}
+
+// DeleteExpired delete expired sessions, call it periodically
+func (a *Store) DeleteExpired() (int64, error) {
+	r, err := a.db.Exec("DELETE FROM "+a.table+" WHERE EXPIRE_AT <= ?", time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
@@ -0,0 +1,284 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+const requestKey = "session"
+
+// Store server side storage of sessions
+type Store interface {
+	// Load returns nil, nil if session does not exist or has expired
+	Load(id string) (map[string]interface{}, error)
+	Save(id string, values map[string]interface{}, ttl time.Duration) error
+	Delete(id string) error
+}
+
+// Session values of a session
+type Session struct {
+	id        string
+	oldID     string // id before rotation
+	values    map[string]interface{}
+	isNew     bool
+	changed   bool
+	destroyed bool
+}
+
+// ID session id
+func (a *Session) ID() string {
+	return a.id
+}
+
+// IsNew true if session is created by this request
+func (a *Session) IsNew() bool {
+	return a.isNew
+}
+
+// Get get value
+func (a *Session) Get(k string) interface{} {
+	return a.values[k]
+}
+
+// Set set value
+func (a *Session) Set(k string, v interface{}) {
+	a.values[k] = v
+	a.changed = true
+}
+
+// Delete delete value
+func (a *Session) Delete(k string) {
+	delete(a.values, k)
+	a.changed = true
+}
+
+// Rotate give session a new id and keep its values, old id becomes invalid
+// call it after login to prevent session fixation
+func (a *Session) Rotate() {
+	if a.oldID == "" && !a.isNew {
+		a.oldID = a.id
+	}
+	a.id = newID()
+	a.changed = true
+}
+
+// Destroy delete session from store and cookie from client
+func (a *Session) Destroy() {
+	a.destroyed = true
+}
+
+// Option session options
+type Option struct {
+	CookieName string // default AURORA_SESSION
+	Path       string // default /
+	Domain     string
+	TTL        time.Duration // idle timeout, default 30 minutes
+	Secure     bool
+	SameSite   http.SameSite // default http.SameSiteLaxMode
+}
+
+// Filter load session into request before handler function, save it after
+type Filter struct {
+	store  Store
+	codec  *Codec
+	option Option
+}
+
+// NewFilter return a session filter, cookie of session id is signed (and encrypted) by codec
+// e.g.
+//     codec, _ := session.NewCodec([]byte("sign key"), "")
+//     s.DynamicFilter("/{url}", session.NewFilter(session.NewMemoryStore(), codec, nil))
+//
+//     func handler(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+//         sess := session.Get(req)
+//         sess.Set("user", "frank")
+//         ...
+//     }
+func NewFilter(store Store, codec *Codec, option *Option) *Filter {
+	f := &Filter{store: store, codec: codec}
+	if option != nil {
+		f.option = *option
+	}
+	if f.option.CookieName == "" {
+		f.option.CookieName = "AURORA_SESSION"
+	}
+	if f.option.Path == "" {
+		f.option.Path = "/"
+	}
+	if f.option.TTL <= 0 {
+		f.option.TTL = 30 * time.Minute
+	}
+	if f.option.SameSite == 0 {
+		f.option.SameSite = http.SameSiteLaxMode
+	}
+	return f
+}
+
+// Get get session loaded by Filter, nil if Filter has not been applied to request
+func Get(req *httpserver.Request) *Session {
+	if s, ok := req.Get(requestKey).(*Session); ok {
+		return s
+	}
+	return nil
+}
+
+// Before load session
+func (a *Filter) Before(rsp *httpserver.Response, r *httpserver.Request) bool {
+	if id, err := a.codec.Cookie(r, a.option.CookieName, 0); err == nil && id != "" {
+		values, err := a.store.Load(id)
+		if err != nil {
+			rsp.WriteStatusCode(http.StatusInternalServerError)
+			return false
+		}
+		if values != nil {
+			r.Set(requestKey, &Session{id: id, values: values})
+			return true
+		}
+	}
+	r.Set(requestKey, &Session{id: newID(), values: make(map[string]interface{}), isNew: true})
+	return true
+}
+
+// After save session and write cookie
+func (a *Filter) After(rsp *httpserver.Response, r *httpserver.Request) {
+	s := Get(r)
+	if s == nil {
+		return
+	}
+	if s.destroyed {
+		if !s.isNew {
+			a.store.Delete(s.id)
+		}
+		if s.oldID != "" {
+			a.store.Delete(s.oldID)
+		}
+		a.setCookie(rsp, "", -1)
+		return
+	}
+	if s.isNew && !s.changed {
+		return // nothing to keep
+	}
+	if s.oldID != "" {
+		a.store.Delete(s.oldID)
+	}
+	// save every time to slide the idle timeout
+	if err := a.store.Save(s.id, s.values, a.option.TTL); err != nil {
+		return
+	}
+	if s.isNew || s.oldID != "" {
+		a.setCookie(rsp, s.id, 0)
+	}
+}
+
+func (a *Filter) setCookie(rsp *httpserver.Response, id string, maxAge int) {
+	a.codec.SetCookie(rsp, &http.Cookie{
+		Name:     a.option.CookieName,
+		Value:    id,
+		Path:     a.option.Path,
+		Domain:   a.option.Domain,
+		MaxAge:   maxAge,
+		Secure:   a.option.Secure,
+		HttpOnly: true,
+		SameSite: a.option.SameSite,
+	})
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+type entry struct {
+	values map[string]interface{}
+	expire time.Time
+}
+
+// MemoryStore in-memory store, sessions are lost when process exits
+type MemoryStore struct {
+	mutex    *sync.Mutex
+	sessions map[string]*entry
+	sweep    time.Time
+}
+
+// NewMemoryStore return an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{mutex: new(sync.Mutex), sessions: make(map[string]*entry), sweep: time.Now()}
+}
+
+// Load load session
+func (a *MemoryStore) Load(id string) (map[string]interface{}, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	e, c := a.sessions[id]
+	if !c {
+		return nil, nil
+	}
+	if time.Now().After(e.expire) {
+		delete(a.sessions, id)
+		return nil, nil
+	}
+	return copyValues(e.values), nil
+}
+
+// Save save session
+func (a *MemoryStore) Save(id string, values map[string]interface{}, ttl time.Duration) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := time.Now()
+	a.sessions[id] = &entry{values: copyValues(values), expire: now.Add(ttl)}
+	if now.Sub(a.sweep) > time.Minute {
+		a.sweep = now
+		for k, e := range a.sessions {
+			if now.After(e.expire) {
+				delete(a.sessions, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Delete delete session
+func (a *MemoryStore) Delete(id string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.sessions, id)
+	return nil
+}
+
+func copyValues(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
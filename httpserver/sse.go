@@ -0,0 +1,210 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultSSEKeepAlive = 15 * time.Second
+
+// ErrStreamClosed client has disconnected or handler function has returned
+var ErrStreamClosed = errors.New("sse: stream closed")
+
+// Event server-sent event
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry int // reconnection time in milliseconds, omitted if Retry <= 0
+}
+
+// EventStream stream of server-sent events
+type EventStream struct {
+	rw        http.ResponseWriter
+	flusher   http.Flusher
+	req       *Request
+	mutex     *sync.Mutex
+	closed    bool
+	done      chan struct{}
+	keepAlive int64 // nanoseconds
+}
+
+// SSE register a server-sent events endpoint, pattern can be static or dynamic
+// headers are sent after all `Before` of matched filters pass,
+// a keepalive comment is sent every 15 seconds, stream is closed when f returns.
+// ServerOption.WriteTimeout does not apply to streams
+// e.g.
+//     s.SSE("/events", func(stream *httpserver.EventStream, req *httpserver.Request) {
+//         id := stream.LastEventID()  // resume after id
+//         for {
+//             select {
+//             case e := <-events:
+//                 stream.Send(e)
+//             case <-stream.Done():  // client disconnected
+//                 return
+//             }
+//         }
+//     })
+func (a *HTTPServer) SSE(pattern string, f func(stream *EventStream, req *Request)) {
+	a.defaultHandler.sse(pattern, f)
+}
+
+func (a *handler) sse(pattern string, f func(stream *EventStream, req *Request)) {
+	a.dynamicRoute(pattern, func(rsp *Response, req *Request) (uint, error) {
+		flusher, ok := rsp.rw.(http.Flusher)
+		if !ok {
+			return http.StatusInternalServerError, errors.New("sse: response does not support flush")
+		}
+		// stream lives longer than write timeout of server
+		http.NewResponseController(rsp.rw).SetWriteDeadline(time.Time{})
+		h := rsp.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no")
+		rsp.WriteHeader(http.StatusOK)
+		rsp.detached = true
+		flusher.Flush()
+
+		stream := &EventStream{
+			rw:        rsp.rw,
+			flusher:   flusher,
+			req:       req,
+			mutex:     new(sync.Mutex),
+			done:      make(chan struct{}),
+			keepAlive: int64(defaultSSEKeepAlive),
+		}
+		go stream.keepAliveLoop()
+		defer stream.close()
+		f(stream, req)
+		return http.StatusOK, nil
+	})
+}
+
+// LastEventID id of last event received by client before reconnection, empty if it is a new connection
+func (a *EventStream) LastEventID() string {
+	if id := a.req.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return a.req.URL.Query().Get("lastEventId")
+}
+
+// Done closed when client disconnects
+func (a *EventStream) Done() <-chan struct{} {
+	return a.req.Context().Done()
+}
+
+// SetKeepAlive set interval of keepalive comments, keepalive is off if d <= 0
+func (a *EventStream) SetKeepAlive(d time.Duration) {
+	atomic.StoreInt64(&a.keepAlive, int64(d))
+}
+
+// Send send an event
+func (a *EventStream) Send(e *Event) error {
+	s := ""
+	if e.ID != "" {
+		s += "id: " + singleLine(e.ID) + "\n"
+	}
+	if e.Event != "" {
+		s += "event: " + singleLine(e.Event) + "\n"
+	}
+	if e.Retry > 0 {
+		s += "retry: " + strconv.Itoa(e.Retry) + "\n"
+	}
+	data := strings.Replace(e.Data, "\r\n", "\n", -1)
+	for _, l := range strings.Split(data, "\n") {
+		s += "data: " + l + "\n"
+	}
+	return a.write(s + "\n")
+}
+
+// SendData send an event which has data only
+func (a *EventStream) SendData(data string) error {
+	return a.Send(&Event{Data: data})
+}
+
+// Comment send a comment, which is ignored by client
+func (a *EventStream) Comment(s string) error {
+	return a.write(": " + singleLine(s) + "\n\n")
+}
+
+func (a *EventStream) write(s string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return ErrStreamClosed
+	}
+	select {
+	case <-a.req.Context().Done():
+		return ErrStreamClosed
+	default:
+	}
+	if _, err := a.rw.Write([]byte(s)); err != nil {
+		return err
+	}
+	a.flusher.Flush()
+	return nil
+}
+
+func (a *EventStream) keepAliveLoop() {
+	for {
+		d := time.Duration(atomic.LoadInt64(&a.keepAlive))
+		if d <= 0 {
+			d = defaultSSEKeepAlive // check again later
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+			if atomic.LoadInt64(&a.keepAlive) > 0 && a.Comment("keepalive") == ErrStreamClosed {
+				return
+			}
+		case <-a.done:
+			t.Stop()
+			return
+		case <-a.req.Context().Done():
+			t.Stop()
+			return
+		}
+	}
+}
+
+func (a *EventStream) close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if !a.closed {
+		a.closed = true
+		close(a.done)
+	}
+}
+
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,200 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// StaticOption static file serving options
+type StaticOption struct {
+	MaxAge  int    // Cache-Control max-age in seconds, no-cache if MaxAge <= 0
+	Listing bool   // list files of directory which has no index file, off by default
+	Index   string // index file of directory, default index.html
+}
+
+type fileServer struct {
+	h        *handler
+	prefix   string
+	root     string
+	maxAge   int
+	listing  bool
+	index    string
+	fallback string // file served when nothing matched, for single page application
+}
+
+// Static serve files under dir with url prefix
+// e.g.
+//     s.Static("/assets", "./web/assets")
+//     /assets/js/app.js  ->  ./web/assets/js/app.js
+func (a *HTTPServer) Static(prefix, dir string) {
+	a.StaticWithOption(prefix, dir, nil)
+}
+
+// StaticWithOption serve files under dir with url prefix
+func (a *HTTPServer) StaticWithOption(prefix, dir string, option *StaticOption) {
+	a.defaultHandler.static(prefix, dir, option)
+}
+
+// SPA serve a single page application under dir with url prefix
+// static routes, dynamic routes and longer static prefixes take precedence,
+// GET and HEAD requests which match no file and have no extension are answered with index,
+// others go to NotFound handler
+// e.g.
+//     s.SPA("/", "./web/dist", "index.html")
+func (a *HTTPServer) SPA(prefix, dir, index string) {
+	a.defaultHandler.spa(prefix, dir, index)
+}
+
+func (a *handler) static(prefix, dir string, option *StaticOption) {
+	fs := newFileServer(a, prefix, dir, option)
+	a.prefixRoute(prefix, fs.serve)
+}
+
+func (a *handler) spa(prefix, dir, index string) {
+	fs := newFileServer(a, prefix, dir, nil)
+	fs.fallback = index
+	a.prefixRoute(prefix, fs.serve)
+}
+
+func newFileServer(h *handler, prefix, dir string, option *StaticOption) *fileServer {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		root = dir
+	}
+	if prefix != "/" {
+		prefix = strings.TrimRight(prefix, "/")
+	}
+	fs := &fileServer{h: h, prefix: prefix, root: root, index: "index.html"}
+	if option != nil {
+		fs.maxAge = option.MaxAge
+		fs.listing = option.Listing
+		if option.Index != "" {
+			fs.index = option.Index
+		}
+	}
+	return fs
+}
+
+func (a *fileServer) serve(rsp *Response, req *Request) (uint, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		rsp.Header().Set("Allow", "GET, HEAD")
+		rsp.WriteStatusCode(http.StatusMethodNotAllowed)
+		return http.StatusMethodNotAllowed, nil
+	}
+	p := req.URL.Path
+	if a.prefix != "/" {
+		p = strings.TrimPrefix(p, a.prefix)
+	}
+	if strings.Contains(p, "\x00") {
+		return a.h.notFound(rsp, req)
+	}
+	p = path.Clean("/" + p) // rooted, so that ".." can never escape root
+	name := filepath.Join(a.root, filepath.FromSlash(p))
+
+	fi, err := os.Stat(name)
+	if err == nil && fi.IsDir() {
+		if fi2, err2 := os.Stat(filepath.Join(name, a.index)); err2 == nil && !fi2.IsDir() {
+			name, fi = filepath.Join(name, a.index), fi2
+		} else if a.listing && a.fallback == "" {
+			return a.list(rsp, req, name, p)
+		} else {
+			err = os.ErrNotExist
+		}
+	}
+	if err != nil {
+		if a.fallback == "" || strings.Contains(path.Base(p), ".") {
+			return a.h.notFound(rsp, req)
+		}
+		name = filepath.Join(a.root, filepath.FromSlash(path.Clean("/"+a.fallback)))
+		if fi, err = os.Stat(name); err != nil || fi.IsDir() {
+			return a.h.notFound(rsp, req)
+		}
+	}
+	return a.serveFile(rsp, req, name, fi)
+}
+
+// serveFile serve file with http.ServeContent, which handles Range, conditional requests and HEAD,
+// file is streamed to client so that response is detached
+func (a *fileServer) serveFile(rsp *Response, req *Request, name string, fi os.FileInfo) (uint, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return a.h.notFound(rsp, req)
+	}
+	defer f.Close()
+	h := rsp.Header()
+	h.Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+	if a.maxAge > 0 {
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(a.maxAge))
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	w := &statusWriter{ResponseWriter: rsp.rw, code: http.StatusOK}
+	rsp.detached = true
+	http.ServeContent(w, req.Request, name, fi.ModTime(), f)
+	rsp.code = w.code
+	return uint(w.code), nil
+}
+
+// statusWriter record status code written
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (a *statusWriter) WriteHeader(code int) {
+	a.code = code
+	a.ResponseWriter.WriteHeader(code)
+}
+
+func (a *fileServer) list(rsp *Response, req *Request, dir, p string) (uint, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return a.h.notFound(rsp, req)
+	}
+	base := strings.TrimRight(a.prefix, "/") + strings.TrimRight(p, "/") + "/"
+	b := new(strings.Builder)
+	b.WriteString("<!DOCTYPE html>\n<pre>\n")
+	for _, fi := range fis {
+		n := fi.Name()
+		if fi.IsDir() {
+			n += "/"
+		}
+		fmt.Fprintf(b, "<a href=\"%s\">%s</a>\n", html.EscapeString(base+n), html.EscapeString(n))
+	}
+	b.WriteString("</pre>\n")
+	rsp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rsp.Write([]byte(b.String()))
+	rsp.WriteStatusCode(http.StatusOK)
+	return http.StatusOK, nil
+}
@@ -0,0 +1,338 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package testserver in memory test harness of httpserver
+//
+// requests run through the whole pipeline of filters and handlers without listening on a port
+// e.g.
+//     ts := testserver.New(t, s)
+//     ts.GET("/users/1").WithHeader("Authorization", "Bearer x").Expect(200).JSONBody(`{"id":1}`)
+//
+// wrap filters with Track to assert which filters ran
+//     s.Filter("/users", ts.Track("auth", authFilter))
+//     ts.GET("/users").Expect(401).Ran("auth").NotRan("handler")
+package testserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+// idKey context key of request id, filters and handlers never see it in headers
+type idKey struct{}
+
+// Server in memory server
+type Server struct {
+	t      testing.TB
+	s      *httpserver.HTTPServer
+	h      http.Handler
+	once   sync.Once
+	seq    int64
+	mutex  *sync.Mutex
+	events map[string][]string // request id -> filter events
+}
+
+// New return a test server of s, register routes and filters before sending requests
+func New(t testing.TB, s *httpserver.HTTPServer) *Server {
+	return &Server{t: t, s: s, mutex: new(sync.Mutex), events: make(map[string][]string)}
+}
+
+// Track wrap filter f, Before and After calls of f are recorded under name
+func (a *Server) Track(name string, f httpserver.Filter) httpserver.Filter {
+	return &tracked{name: name, f: f, s: a}
+}
+
+// GET new a GET request
+func (a *Server) GET(path string) *Request {
+	return a.NewRequest(http.MethodGet, path)
+}
+
+// HEAD new a HEAD request
+func (a *Server) HEAD(path string) *Request {
+	return a.NewRequest(http.MethodHead, path)
+}
+
+// POST new a POST request
+func (a *Server) POST(path string) *Request {
+	return a.NewRequest(http.MethodPost, path)
+}
+
+// PUT new a PUT request
+func (a *Server) PUT(path string) *Request {
+	return a.NewRequest(http.MethodPut, path)
+}
+
+// PATCH new a PATCH request
+func (a *Server) PATCH(path string) *Request {
+	return a.NewRequest(http.MethodPatch, path)
+}
+
+// DELETE new a DELETE request
+func (a *Server) DELETE(path string) *Request {
+	return a.NewRequest(http.MethodDelete, path)
+}
+
+// NewRequest new a request
+func (a *Server) NewRequest(method, path string) *Request {
+	return &Request{s: a, method: method, path: path, header: make(http.Header), query: make(url.Values)}
+}
+
+func (a *Server) record(r *httpserver.Request, event string) {
+	id, _ := r.Context().Value(idKey{}).(string)
+	a.mutex.Lock()
+	a.events[id] = append(a.events[id], event)
+	a.mutex.Unlock()
+}
+
+// Request request builder
+type Request struct {
+	s      *Server
+	method string
+	path   string
+	host   string
+	header http.Header
+	query  url.Values
+	body   []byte
+}
+
+// WithHeader set header
+func (a *Request) WithHeader(k, v string) *Request {
+	a.header.Set(k, v)
+	return a
+}
+
+// WithHost set host of request, default example.com
+func (a *Request) WithHost(host string) *Request {
+	a.host = host
+	return a
+}
+
+// WithQuery add query parameter
+func (a *Request) WithQuery(k, v string) *Request {
+	a.query.Add(k, v)
+	return a
+}
+
+// WithBody set body
+func (a *Request) WithBody(b []byte) *Request {
+	a.body = b
+	return a
+}
+
+// WithJSON set body to json of v and content type to application/json
+func (a *Request) WithJSON(v interface{}) *Request {
+	b, err := json.Marshal(v)
+	if err != nil {
+		a.s.t.Fatalf("testserver: marshal body: %v", err)
+	}
+	a.header.Set("Content-Type", "application/json")
+	return a.WithBody(b)
+}
+
+// Do send request, it never fails the test
+func (a *Request) Do() *Result {
+	var body io.Reader
+	if a.body != nil {
+		body = bytes.NewReader(a.body)
+	}
+	target := a.path
+	if len(a.query) > 0 {
+		target += "?" + a.query.Encode()
+	}
+	r := httptest.NewRequest(a.method, target, body)
+	for k, v := range a.header {
+		r.Header[k] = v
+	}
+	if a.host != "" {
+		r.Host = a.host
+	}
+	id := strconv.FormatInt(atomic.AddInt64(&a.s.seq, 1), 10)
+	r = r.WithContext(context.WithValue(r.Context(), idKey{}, id))
+	rec := httptest.NewRecorder()
+	a.s.once.Do(func() { a.s.h = a.s.s.Handler() })
+	a.s.h.ServeHTTP(rec, r)
+
+	a.s.mutex.Lock()
+	events := a.s.events[id]
+	delete(a.s.events, id)
+	a.s.mutex.Unlock()
+	return &Result{t: a.s.t, Recorder: rec, events: events}
+}
+
+// Expect send request and assert status code
+func (a *Request) Expect(code int) *Result {
+	a.s.t.Helper()
+	return a.Do().Status(code)
+}
+
+// Result response of a request
+type Result struct {
+	t        testing.TB
+	Recorder *httptest.ResponseRecorder
+	events   []string // e.g. auth.Before, auth.After
+}
+
+// Status assert status code
+func (a *Result) Status(code int) *Result {
+	a.t.Helper()
+	if a.Recorder.Code != code {
+		a.t.Errorf("testserver: expect status %d, got %d, body: %s", code, a.Recorder.Code, a.Recorder.Body.String())
+	}
+	return a
+}
+
+// Header assert header value
+func (a *Result) Header(k, v string) *Result {
+	a.t.Helper()
+	if got := a.Recorder.Header().Get(k); got != v {
+		a.t.Errorf("testserver: expect header %s %q, got %q", k, v, got)
+	}
+	return a
+}
+
+// Body assert body
+func (a *Result) Body(s string) *Result {
+	a.t.Helper()
+	if got := a.Recorder.Body.String(); got != s {
+		a.t.Errorf("testserver: expect body %q, got %q", s, got)
+	}
+	return a
+}
+
+// JSONBody assert body is json equal to expected, expected is a json string, []byte or a value to marshal
+func (a *Result) JSONBody(expected interface{}) *Result {
+	a.t.Helper()
+	var b []byte
+	switch v := expected.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			a.t.Fatalf("testserver: marshal expected body: %v", err)
+		}
+	}
+	var want, got interface{}
+	if err := json.Unmarshal(b, &want); err != nil {
+		a.t.Fatalf("testserver: invalid expected json: %v", err)
+	}
+	if err := json.Unmarshal(a.Recorder.Body.Bytes(), &got); err != nil {
+		a.t.Errorf("testserver: body is not json: %v, body: %s", err, a.Recorder.Body.String())
+		return a
+	}
+	if !reflect.DeepEqual(want, got) {
+		a.t.Errorf("testserver: expect json body %s, got %s", b, a.Recorder.Body.String())
+	}
+	return a
+}
+
+// Decode unmarshal json body into v
+func (a *Result) Decode(v interface{}) *Result {
+	a.t.Helper()
+	if err := json.Unmarshal(a.Recorder.Body.Bytes(), v); err != nil {
+		a.t.Errorf("testserver: decode body: %v", err)
+	}
+	return a
+}
+
+// Ran assert Before of tracked filters ran
+func (a *Result) Ran(names ...string) *Result {
+	a.t.Helper()
+	for _, n := range names {
+		if !a.has(n + ".Before") {
+			a.t.Errorf("testserver: filter %s did not run, filters: %v", n, a.events)
+		}
+	}
+	return a
+}
+
+// NotRan assert Before of tracked filters did not run
+func (a *Result) NotRan(names ...string) *Result {
+	a.t.Helper()
+	for _, n := range names {
+		if a.has(n + ".Before") {
+			a.t.Errorf("testserver: filter %s should not run, filters: %v", n, a.events)
+		}
+	}
+	return a
+}
+
+// Events assert exact sequence of tracked filter calls, e.g. "auth.Before", "log.Before", "log.After", "auth.After"
+func (a *Result) Events(events ...string) *Result {
+	a.t.Helper()
+	if len(events) == 0 && len(a.events) == 0 {
+		return a
+	}
+	if !reflect.DeepEqual(events, a.events) {
+		a.t.Errorf("testserver: expect filter events %v, got %v", events, a.events)
+	}
+	return a
+}
+
+// Then return the raw response
+func (a *Result) Then() *http.Response {
+	rsp := a.Recorder.Result()
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(a.Recorder.Body.Bytes()))
+	return rsp
+}
+
+func (a *Result) has(event string) bool {
+	for _, e := range a.events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type tracked struct {
+	name string
+	f    httpserver.Filter
+	s    *Server
+}
+
+func (a *tracked) Before(rsp *httpserver.Response, req *httpserver.Request) bool {
+	a.s.record(req, a.name+".Before")
+	return a.f.Before(rsp, req)
+}
+
+func (a *tracked) After(rsp *httpserver.Response, req *httpserver.Request) {
+	a.s.record(req, a.name+".After")
+	a.f.After(rsp, req)
+}
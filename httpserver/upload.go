@@ -0,0 +1,215 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrUploadTooLarge request body exceeds UploadOption.MaxSize
+	ErrUploadTooLarge = errors.New("httpserver: upload too large")
+
+	// ErrUploadType sniffed content type of an uploaded file is not allowed
+	ErrUploadType = errors.New("httpserver: upload content type not allowed")
+)
+
+const (
+	defaultUploadMaxSize   = 32 << 20
+	defaultUploadMaxMemory = 1 << 20
+)
+
+// UploadOption multipart upload options
+type UploadOption struct {
+	MaxSize      int64    // max size of whole request body, default 32MB
+	MaxMemory    int64    // memory budget of the whole form, file parts beyond it are spooled to temp files, default 1MB
+	AllowedTypes []string // sniffed content types allowed, e.g. image/png or image/*, empty allows all
+}
+
+// File uploaded file
+type File struct {
+	Field       string
+	Filename    string // base name sent by client, never use it as a path directly
+	Size        int64
+	ContentType string // sniffed from content, not the one sent by client
+	Header      textproto.MIMEHeader
+	fh          *multipart.FileHeader
+}
+
+// Open open uploaded file
+func (a *File) Open() (multipart.File, error) {
+	return a.fh.Open()
+}
+
+// SaveTo copy uploaded file to path
+func (a *File) SaveTo(path string) error {
+	src, err := a.fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// Files get uploaded files of field
+// multipart form is parsed at first call, temp files are removed after handler and `After` filters finish
+// returns ErrUploadTooLarge or ErrUploadType if request breaks upload options of route
+func (a *Request) Files(field string) ([]*File, error) {
+	if err := a.parseMultipart(); err != nil {
+		return nil, err
+	}
+	headers := a.MultipartForm.File[field]
+	files := make([]*File, 0, len(headers))
+	for _, fh := range headers {
+		f := &File{Field: field, Filename: filepath.Base(fh.Filename), Size: fh.Size, Header: fh.Header, fh: fh}
+		ct, err := sniff(fh)
+		if err != nil {
+			return nil, err
+		}
+		if !allowedType(a.upload.AllowedTypes, ct) {
+			return nil, ErrUploadType
+		}
+		f.ContentType = ct
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// File get first uploaded file of field, nil if there is no file
+func (a *Request) File(field string) (*File, error) {
+	files, err := a.Files(field)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+func (a *Request) parseMultipart() error {
+	if a.uploadErr != nil {
+		return a.uploadErr
+	}
+	if a.upload == nil {
+		a.upload = &UploadOption{}
+	}
+	maxSize, maxMemory := a.upload.MaxSize, a.upload.MaxMemory
+	if maxSize <= 0 {
+		maxSize = defaultUploadMaxSize
+	}
+	if maxMemory <= 0 {
+		maxMemory = defaultUploadMaxMemory
+	}
+	if a.ContentLength > maxSize {
+		a.uploadErr = ErrUploadTooLarge
+		return a.uploadErr
+	}
+	if a.MultipartForm != nil {
+		// parsed by FormValue or ParseMultipartForm without limits of route
+		if formSize(a.MultipartForm) > maxSize {
+			a.uploadErr = ErrUploadTooLarge
+		}
+		return a.uploadErr
+	}
+	a.Body = http.MaxBytesReader(nil, a.Body, maxSize)
+	if err := a.ParseMultipartForm(maxMemory); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			err = ErrUploadTooLarge
+		}
+		a.uploadErr = err
+	}
+	return a.uploadErr
+}
+
+func formSize(form *multipart.Form) int64 {
+	var n int64
+	for k, vs := range form.Value {
+		for _, v := range vs {
+			n += int64(len(k) + len(v))
+		}
+	}
+	for _, fhs := range form.File {
+		for _, fh := range fhs {
+			n += fh.Size
+		}
+	}
+	return n
+}
+
+// cleanup remove temp files of multipart form
+func (a *Request) cleanup() {
+	if a.MultipartForm != nil {
+		a.MultipartForm.RemoveAll()
+	}
+}
+
+func sniff(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b := make([]byte, 512)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(b[:n]), nil
+}
+
+func allowedType(allowed []string, ct string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	for _, t := range allowed {
+		if t == ct || strings.HasSuffix(t, "/*") && strings.HasPrefix(ct, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Upload set upload options of route pattern, e.g. /avatar/{id}
+// routes without options use default options
+func (a *HTTPServer) Upload(pattern string, option *UploadOption) {
+	a.defaultHandler.uploads[pattern] = option
+}
@@ -0,0 +1,550 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package httpserver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// websocket message types, see RFC 6455
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// websocket close codes, see RFC 6455
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	websocketGUID       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultReadLimit    = 32 << 20
+	defaultWriteTimeout = 10 * time.Second
+)
+
+// ErrWSClosed websocket connection has been closed
+var ErrWSClosed = errors.New("websocket: connection closed")
+
+// CloseError returned by ReadMessage when a close frame is received
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (a *CloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(a.Code) + " " + a.Text
+}
+
+// WSConn websocket connection
+type WSConn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	wmutex       *sync.Mutex
+	writeTimeout time.Duration
+	readLimit    int64
+	pingWait     int64 // nanoseconds, extend read deadline by pingWait on every frame if keepalive is on, accessed atomically
+	closeSent    bool
+	closed       chan struct{}
+	closeOnce    sync.Once
+	pongHandler  func(data []byte)
+}
+
+// WSOption websocket options
+type WSOption struct {
+	// CheckOrigin return false to refuse handshake with 403,
+	// by default Origin must be absent or have the same host as request, see SameOrigin
+	CheckOrigin func(req *Request) bool
+}
+
+// WebSocket register a websocket endpoint, pattern can be static or dynamic
+// the handshake is done after all `Before` of matched filters pass, so filters can be used for auth.
+// cross-origin handshakes are refused, see WebSocketWithOption.
+// the connection is closed when f returns, and then `After` of filters work
+// e.g.
+//     s.WebSocket("/ws/{room}", func(conn *httpserver.WSConn, req *httpserver.Request) {
+//         for {
+//             t, b, err := conn.ReadMessage()
+//             if err != nil {
+//                 return
+//             }
+//             conn.WriteMessage(t, b)
+//         }
+//     })
+func (a *HTTPServer) WebSocket(pattern string, f func(conn *WSConn, req *Request)) {
+	a.defaultHandler.webSocket(pattern, nil, f)
+}
+
+// WebSocketWithOption register a websocket endpoint with option
+// e.g. allow pages of another site
+//     s.WebSocketWithOption("/ws", &httpserver.WSOption{CheckOrigin: func(req *httpserver.Request) bool {
+//         return req.Header.Get("Origin") == "https://app.example.com"
+//     }}, f)
+func (a *HTTPServer) WebSocketWithOption(pattern string, option *WSOption, f func(conn *WSConn, req *Request)) {
+	a.defaultHandler.webSocket(pattern, option, f)
+}
+
+func (a *handler) webSocket(pattern string, option *WSOption, f func(conn *WSConn, req *Request)) {
+	checkOrigin := SameOrigin
+	if option != nil && option.CheckOrigin != nil {
+		checkOrigin = option.CheckOrigin
+	}
+	a.dynamicRoute(pattern, func(rsp *Response, req *Request) (uint, error) {
+		if !checkOrigin(req) {
+			rsp.WriteStatusCode(http.StatusForbidden)
+			rsp.Write([]byte("websocket: origin not allowed"))
+			return http.StatusForbidden, nil
+		}
+		conn, code, err := upgrade(rsp, req)
+		if err != nil {
+			if code == http.StatusInternalServerError {
+				return uint(code), err
+			}
+			rsp.WriteStatusCode(code)
+			rsp.Write([]byte(err.Error()))
+			return uint(code), nil
+		}
+		defer conn.Close()
+		f(conn, req)
+		return http.StatusSwitchingProtocols, nil
+	})
+}
+
+// SameOrigin true if request has no Origin header, or host of Origin equals to host of request
+func SameOrigin(req *Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, req.Host)
+}
+
+func upgrade(rsp *Response, req *Request) (*WSConn, int, error) {
+	if req.Method != http.MethodGet {
+		rsp.Header().Set("Allow", http.MethodGet)
+		return nil, http.StatusMethodNotAllowed, errors.New("websocket: method not allowed")
+	}
+	if !headerContains(req.Header, "Connection", "upgrade") || !headerContains(req.Header, "Upgrade", "websocket") {
+		return nil, http.StatusBadRequest, errors.New("websocket: not a websocket handshake")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		rsp.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, http.StatusUpgradeRequired, errors.New("websocket: unsupported version")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, http.StatusBadRequest, errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+	hj, ok := rsp.rw.(http.Hijacker)
+	if !ok {
+		return nil, http.StatusInternalServerError, errors.New("websocket: response does not support hijack")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	rsp.detached = true
+	rsp.code = http.StatusSwitchingProtocols
+
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	s := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + accept + "\r\n"
+	for k, vs := range rsp.Header() {
+		for _, v := range vs {
+			s += k + ": " + v + "\r\n"
+		}
+	}
+	s += "\r\n"
+	conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
+	if _, err = conn.Write([]byte(s)); err != nil {
+		conn.Close()
+		return nil, http.StatusInternalServerError, err
+	}
+	conn.SetDeadline(time.Time{})
+	return &WSConn{
+		conn:         conn,
+		br:           brw.Reader,
+		wmutex:       new(sync.Mutex),
+		writeTimeout: defaultWriteTimeout,
+		readLimit:    defaultReadLimit,
+		closed:       make(chan struct{}),
+	}, http.StatusSwitchingProtocols, nil
+}
+
+func headerContains(h http.Header, k, v string) bool {
+	for _, s := range h[http.CanonicalHeaderKey(k)] {
+		for _, t := range strings.Split(s, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReadLimit set max size of a message, default 32MB.
+// connection is closed with CloseMessageTooBig if a message exceeds it
+func (a *WSConn) SetReadLimit(n int64) {
+	a.readLimit = n
+}
+
+// SetWriteTimeout set timeout of each write, default 10 seconds
+func (a *WSConn) SetWriteTimeout(d time.Duration) {
+	a.writeTimeout = d
+}
+
+// SetReadDeadline set read deadline of underlying connection
+func (a *WSConn) SetReadDeadline(t time.Time) error {
+	return a.conn.SetReadDeadline(t)
+}
+
+// SetPongHandler set function which is called when a pong is received
+func (a *WSConn) SetPongHandler(f func(data []byte)) {
+	a.pongHandler = f
+}
+
+// KeepAlive send a ping every interval,
+// ReadMessage fails if nothing is received from peer in interval+timeout
+func (a *WSConn) KeepAlive(interval, timeout time.Duration) {
+	wait := interval + timeout
+	atomic.StoreInt64(&a.pingWait, int64(wait))
+	a.conn.SetReadDeadline(time.Now().Add(wait))
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if a.Ping(nil) != nil {
+					return
+				}
+			case <-a.closed:
+				return
+			}
+		}
+	}()
+}
+
+// RemoteAddr remote address
+func (a *WSConn) RemoteAddr() net.Addr {
+	return a.conn.RemoteAddr()
+}
+
+// ReadMessage read a complete message, fragmented messages are reassembled.
+// pings are answered automatically.
+// returns *CloseError if peer closes the connection
+func (a *WSConn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	var message []byte
+	for {
+		fin, opcode, payload, err := a.readFrame()
+		if err != nil {
+			if ce, ok := err.(*CloseError); ok && ce.Code != CloseNormalClosure {
+				a.WriteClose(ce.Code, ce.Text)
+				a.Close()
+			}
+			return 0, nil, err
+		}
+		switch opcode {
+		case PingMessage:
+			a.writeFrame(PongMessage, payload)
+			continue
+		case PongMessage:
+			if a.pongHandler != nil {
+				a.pongHandler(payload)
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, a.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, a.fail(CloseProtocolError, "new message before last one finished")
+			}
+			messageType = opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, a.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, a.fail(CloseProtocolError, "unknown opcode")
+		}
+		if int64(len(message)+len(payload)) > a.readLimit {
+			return 0, nil, a.fail(CloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, a.fail(CloseInvalidFramePayloadData, "invalid utf-8")
+			}
+			if message == nil {
+				message = []byte{}
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+func (a *WSConn) readFrame() (bool, int, []byte, error) {
+	var h [14]byte
+	if _, err := io.ReadFull(a.br, h[:2]); err != nil {
+		return false, 0, nil, err
+	}
+	if wait := atomic.LoadInt64(&a.pingWait); wait > 0 {
+		a.conn.SetReadDeadline(time.Now().Add(time.Duration(wait)))
+	}
+	fin := h[0]&0x80 != 0
+	opcode := int(h[0] & 0x0f)
+	if h[0]&0x70 != 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "reserved bits set"}
+	}
+	if h[1]&0x80 == 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "frame from client is not masked"}
+	}
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		if _, err := io.ReadFull(a.br, h[2:4]); err != nil {
+			return false, 0, nil, err
+		}
+		n = int64(binary.BigEndian.Uint16(h[2:4]))
+	case 127:
+		if _, err := io.ReadFull(a.br, h[2:10]); err != nil {
+			return false, 0, nil, err
+		}
+		u := binary.BigEndian.Uint64(h[2:10])
+		if u>>63 != 0 {
+			return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "invalid payload length"}
+		}
+		n = int64(u)
+	}
+	if opcode >= CloseMessage && (!fin || n > 125) {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Text: "invalid control frame"}
+	}
+	if n > a.readLimit {
+		return false, 0, nil, &CloseError{Code: CloseMessageTooBig, Text: "message too big"}
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(a.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(a.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+func (a *WSConn) handleClose(payload []byte) error {
+	ce := &CloseError{Code: CloseNoStatusReceived}
+	code := CloseNormalClosure
+	switch {
+	case len(payload) == 1:
+		ce.Code, code = CloseProtocolError, CloseProtocolError
+	case len(payload) >= 2:
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		ce.Text = string(payload[2:])
+		if !validCloseCode(ce.Code) || !utf8.ValidString(ce.Text) {
+			code = CloseProtocolError
+		} else {
+			code = ce.Code
+		}
+	}
+	a.WriteClose(code, "")
+	a.Close()
+	return ce
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		return code != 1004 && code != CloseNoStatusReceived && code != CloseAbnormalClosure
+	}
+	return false
+}
+
+func (a *WSConn) fail(code int, text string) error {
+	a.WriteClose(code, text)
+	a.Close()
+	return &CloseError{Code: code, Text: text}
+}
+
+// WriteMessage write a text or binary message
+func (a *WSConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errors.New("websocket: unknown message type")
+	}
+	return a.writeFrame(messageType, data)
+}
+
+// Ping send a ping
+func (a *WSConn) Ping(data []byte) error {
+	return a.writeFrame(PingMessage, data)
+}
+
+// WriteClose send a close frame with code and reason
+// the connection should be closed after that
+func (a *WSConn) WriteClose(code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	b := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	b = append(b, reason...)
+	return a.writeFrame(CloseMessage, b)
+}
+
+func (a *WSConn) writeFrame(opcode int, data []byte) error {
+	a.wmutex.Lock()
+	defer a.wmutex.Unlock()
+	if a.closeSent {
+		return ErrWSClosed
+	}
+	if opcode == CloseMessage {
+		a.closeSent = true
+	}
+	h := make([]byte, 2, 10+len(data))
+	h[0] = 0x80 | byte(opcode)
+	switch n := len(data); {
+	case n <= 125:
+		h[1] = byte(n)
+	case n <= 0xffff:
+		h[1] = 126
+		h = h[:4]
+		binary.BigEndian.PutUint16(h[2:], uint16(n))
+	default:
+		h[1] = 127
+		h = h[:10]
+		binary.BigEndian.PutUint64(h[2:], uint64(n))
+	}
+	if a.writeTimeout > 0 {
+		a.conn.SetWriteDeadline(time.Now().Add(a.writeTimeout))
+	}
+	_, err := a.conn.Write(append(h, data...))
+	return err
+}
+
+// Close send a normal close frame if no close frame has been sent, then close underlying connection
+func (a *WSConn) Close() error {
+	var err error
+	a.closeOnce.Do(func() {
+		a.WriteClose(CloseNormalClosure, "")
+		close(a.closed)
+		err = a.conn.Close()
+	})
+	return err
+}
+
+// WSHub a group of websocket connections, messages can be broadcast to all of them
+type WSHub struct {
+	mutex *sync.RWMutex
+	conns map[*WSConn]bool
+}
+
+// NewWSHub return an empty hub
+func NewWSHub() *WSHub {
+	return &WSHub{mutex: new(sync.RWMutex), conns: make(map[*WSConn]bool)}
+}
+
+// Register add conn to hub
+func (a *WSHub) Register(conn *WSConn) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.conns[conn] = true
+}
+
+// Unregister remove conn from hub
+func (a *WSHub) Unregister(conn *WSConn) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.conns, conn)
+}
+
+// Len count of connections in hub
+func (a *WSHub) Len() int {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return len(a.conns)
+}
+
+// Broadcast write message to all connections,
+// connections failed to write are closed and removed from hub
+func (a *WSHub) Broadcast(messageType int, data []byte) {
+	a.mutex.RLock()
+	conns := make([]*WSConn, 0, len(a.conns))
+	for c := range a.conns {
+		conns = append(conns, c)
+	}
+	a.mutex.RUnlock()
+	wg := new(sync.WaitGroup)
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *WSConn) {
+			defer wg.Done()
+			if c.WriteMessage(messageType, data) != nil {
+				a.Unregister(c)
+				c.Close()
+			}
+		}(c)
+	}
+	wg.Wait()
+}
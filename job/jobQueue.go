@@ -27,6 +27,8 @@ package job
 import (
 	"sync/atomic"
 	"time"
+
+	"github.com/FrankLeeC/Aurora/metrics"
 )
 
 // var queue chan Job
@@ -55,6 +57,11 @@ func (jobQueue *JobQueue) Submit(job Job) {
 	}
 }
 
+// Backlog count of jobs waiting in queue
+func (jobQueue *JobQueue) Backlog() int {
+	return len(jobQueue.queue)
+}
+
 // SubmitTimeout submit job in queue with timeout in millisecond
 func (jobQueue *JobQueue) SubmitTimeout(job Job, timeout int) bool {
 	if timeout <= 0 {
@@ -137,3 +144,16 @@ func (jobQueue *JobQueue) run() {
 		jobChan <- job
 	}
 }
+
+// RegisterMetrics register gauges of this job queue in registry r
+// name is the value of label `queue`
+func (jobQueue *JobQueue) RegisterMetrics(r *metrics.Registry, name string) {
+	waiting := r.Gauge("job_queue_waiting", "Count of jobs waiting in queue.", "queue")
+	capacity := r.Gauge("job_queue_capacity", "Capacity of queue.", "queue")
+	dispatched := r.Gauge("job_queue_dispatched", "Count of jobs dispatched to workers.", "queue")
+	r.OnCollect(func() {
+		waiting.Set(float64(len(jobQueue.queue)), name)
+		capacity.Set(float64(cap(jobQueue.queue)), name)
+		dispatched.Set(float64(atomic.LoadInt64(&jobQueue.num)), name)
+	})
+}
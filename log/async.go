@@ -0,0 +1,192 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// overflow policies of AsyncSink
+const (
+	// OverflowBlock callers wait until there is room in queue
+	OverflowBlock = iota
+	// OverflowDropNewest discard the entry being logged
+	OverflowDropNewest
+	// OverflowDropOldest discard the oldest queued entry
+	OverflowDropOldest
+)
+
+// AsyncOption sink sink options
+type AsyncOption struct {
+	QueueSize int // capacity of ring buffer, default 8192
+	BatchSize int // max entries written in a batch, default 256
+	Overflow  int // OverflowBlock, OverflowDropNewest or OverflowDropOldest, default OverflowBlock
+}
+
+// batchWriter sinks writing several entries at once
+type batchWriter interface {
+	WriteBatch(es []*Entry) error
+}
+
+// AsyncSink queue entries in a ring buffer, a background goroutine writes them to sink in batches
+// call Flush or Close before exiting, otherwise queued entries are lost
+type AsyncSink struct {
+	next      Sink
+	batchSize int
+	overflow  int
+	mutex     *sync.Mutex
+	notEmpty  *sync.Cond
+	notFull   *sync.Cond
+	written   *sync.Cond
+	queue     []*Entry
+	head      int // index of the oldest entry
+	count     int
+	enqueued  uint64 // entries queued or dropped from queue
+	done      uint64 // entries written or dropped from queue
+	dropped   uint64
+	closed    bool
+	exited    chan struct{}
+}
+
+// NewAsyncSink wrap sink with a bounded queue
+func NewAsyncSink(next Sink, option *AsyncOption) *AsyncSink {
+	o := AsyncOption{}
+	if option != nil {
+		o = *option
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 8192
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 256
+	}
+	sink := &AsyncSink{next: next, batchSize: o.BatchSize, overflow: o.Overflow, queue: make([]*Entry, o.QueueSize), exited: make(chan struct{}), mutex: new(sync.Mutex)}
+	sink.notEmpty = sync.NewCond(sink.mutex)
+	sink.notFull = sync.NewCond(sink.mutex)
+	sink.written = sync.NewCond(sink.mutex)
+	go sink.run()
+	return sink
+}
+
+// Write queue an entry, it returns ErrSinkClosed after Close
+func (sink *AsyncSink) Write(e *Entry) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	for sink.count == len(sink.queue) && !sink.closed {
+		switch sink.overflow {
+		case OverflowDropNewest:
+			atomic.AddUint64(&sink.dropped, 1)
+			return nil
+		case OverflowDropOldest:
+			sink.queue[sink.head] = nil
+			sink.head = (sink.head + 1) % len(sink.queue)
+			sink.count--
+			sink.done++
+			atomic.AddUint64(&sink.dropped, 1)
+		default:
+			sink.notFull.Wait()
+		}
+	}
+	if sink.closed {
+		return ErrSinkClosed
+	}
+	sink.queue[(sink.head+sink.count)%len(sink.queue)] = e
+	sink.count++
+	sink.enqueued++
+	sink.notEmpty.Signal()
+	return nil
+}
+
+// Dropped number of entries dropped by overflow policy
+func (sink *AsyncSink) Dropped() uint64 {
+	return atomic.LoadUint64(&sink.dropped)
+}
+
+// Flush wait until entries queued before Flush are written
+func (sink *AsyncSink) Flush() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	target := sink.enqueued
+	for sink.done < target {
+		sink.written.Wait()
+	}
+	if f, ok := sink.next.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close write queued entries and close sink
+func (sink *AsyncSink) Close() error {
+	sink.mutex.Lock()
+	if sink.closed {
+		sink.mutex.Unlock()
+		return nil
+	}
+	sink.closed = true
+	sink.notEmpty.Signal()
+	sink.notFull.Broadcast()
+	sink.mutex.Unlock()
+	<-sink.exited
+	return sink.next.Close()
+}
+
+func (sink *AsyncSink) run() {
+	defer close(sink.exited)
+	batch := make([]*Entry, 0, sink.batchSize)
+	for {
+		sink.mutex.Lock()
+		for sink.count == 0 && !sink.closed {
+			sink.notEmpty.Wait()
+		}
+		if sink.count == 0 && sink.closed {
+			sink.mutex.Unlock()
+			return
+		}
+		for sink.count > 0 && len(batch) < sink.batchSize {
+			batch = append(batch, sink.queue[sink.head])
+			sink.queue[sink.head] = nil
+			sink.head = (sink.head + 1) % len(sink.queue)
+			sink.count--
+		}
+		sink.notFull.Broadcast()
+		sink.mutex.Unlock()
+
+		if w, ok := sink.next.(batchWriter); ok {
+			w.WriteBatch(batch)
+		} else {
+			for _, e := range batch {
+				sink.next.Write(e)
+			}
+		}
+
+		sink.mutex.Lock()
+		sink.done += uint64(len(batch))
+		sink.written.Broadcast()
+		sink.mutex.Unlock()
+		batch = batch[:0]
+	}
+}
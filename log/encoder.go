@@ -0,0 +1,240 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+var levelNames = []string{"TRACE", "INFO", "WARN", "ERROR", "FATAL"}
+
+var levelColors = []uint8{green, blue, yellow, magenta, red}
+
+// Field a key value pair of structured logs
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Entry a log record
+type Entry struct {
+	Time    time.Time
+	Level   int
+	Message string
+	Caller  string // file:line, empty if mode of logger has no file flag
+	Fields  []Field
+}
+
+// Encoder encode an entry into a line without line break
+type Encoder interface {
+	Encode(e *Entry) []byte
+}
+
+// TextEncoder human readable format, e.g.
+//     [INFO] 2018-03-04 10:00:00.123 main.go:12:user login user=frank ip=127.0.0.1
+type TextEncoder struct {
+	Mode  int  // Day, Time or Std, see LoggerOption
+	Color bool // color level with ansi codes, only for terminals
+}
+
+// Encode encode entry
+func (a *TextEncoder) Encode(e *Entry) []byte {
+	buf := &bytes.Buffer{}
+	tag := "[" + levelName(e.Level) + "]"
+	if a.Color && e.Level >= Trace && e.Level <= Fatal {
+		fmt.Fprintf(buf, "\x1b[%dm%s\x1b[0m", levelColors[e.Level], tag)
+	} else {
+		buf.WriteString(tag)
+	}
+	buf.WriteByte(' ')
+	if a.Mode == 0 || a.Mode == Std {
+		buf.WriteString(e.Time.Format("2006-01-02 15:04:05.999") + " ")
+	} else {
+		if a.Mode&Day == Day {
+			buf.WriteString(e.Time.Format("2006-01-02") + " ")
+		}
+		if a.Mode&Time == Time {
+			buf.WriteString(e.Time.Format("15:04:05") + " ")
+		}
+	}
+	if e.Caller != "" {
+		buf.WriteString(e.Caller + ":")
+	}
+	buf.WriteString(e.Message)
+	for _, f := range e.Fields {
+		buf.WriteByte(' ')
+		writeLogfmt(buf, f.Key, f.Value)
+	}
+	return buf.Bytes()
+}
+
+// JSONEncoder one json object per line, e.g.
+//     {"time":"2018-03-04T10:00:00.123+08:00","level":"info","caller":"main.go:12","msg":"user login","user":"frank"}
+type JSONEncoder struct{}
+
+// Encode encode entry
+func (a *JSONEncoder) Encode(e *Entry) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, e.Time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, lower(levelName(e.Level)))
+	if e.Caller != "" {
+		buf.WriteString(`,"caller":`)
+		writeJSON(buf, e.Caller)
+	}
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, e.Message)
+	for _, f := range e.Fields {
+		buf.WriteByte(',')
+		writeJSON(buf, f.Key)
+		buf.WriteByte(':')
+		writeJSON(buf, jsonValue(f.Value))
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// LogfmtEncoder logfmt format, e.g.
+//     time=2018-03-04T10:00:00.123+08:00 level=info caller=main.go:12 msg="user login" user=frank
+type LogfmtEncoder struct{}
+
+// Encode encode entry
+func (a *LogfmtEncoder) Encode(e *Entry) []byte {
+	buf := &bytes.Buffer{}
+	writeLogfmt(buf, "time", e.Time.Format(time.RFC3339Nano))
+	buf.WriteByte(' ')
+	writeLogfmt(buf, "level", lower(levelName(e.Level)))
+	if e.Caller != "" {
+		buf.WriteByte(' ')
+		writeLogfmt(buf, "caller", e.Caller)
+	}
+	buf.WriteByte(' ')
+	writeLogfmt(buf, "msg", e.Message)
+	for _, f := range e.Fields {
+		buf.WriteByte(' ')
+		writeLogfmt(buf, f.Key, f.Value)
+	}
+	return buf.Bytes()
+}
+
+func levelName(level int) string {
+	if level >= Trace && level <= Fatal {
+		return levelNames[level]
+	}
+	return strconv.Itoa(level)
+}
+
+func lower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// fields make fields of key value pairs, a non-string key is logged with key !BADKEY
+func fields(kv []interface{}) []Field {
+	fs := make([]Field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i++ {
+		k, ok := kv[i].(string)
+		if !ok || i == len(kv)-1 {
+			fs = append(fs, Field{Key: "!BADKEY", Value: kv[i]})
+			continue
+		}
+		fs = append(fs, Field{Key: k, Value: kv[i+1]})
+		i++
+	}
+	return fs
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	}
+	return v
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	buf.Write(b)
+}
+
+func writeLogfmt(buf *bytes.Buffer, k string, v interface{}) {
+	buf.WriteString(k)
+	buf.WriteByte('=')
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case error:
+		s = t.Error()
+	case time.Time:
+		s = t.Format(time.RFC3339Nano)
+	case nil:
+		s = "null"
+	default:
+		s = fmt.Sprint(v)
+	}
+	if needQuote(s) {
+		s = strconv.Quote(s)
+	}
+	buf.WriteString(s)
+}
+
+func needQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// isTerminal whether f is a terminal
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
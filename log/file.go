@@ -0,0 +1,402 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package log
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	dateRegexpStr = `_\d{8}_`
+	dateRexexp    = regexp.MustCompile(dateRegexpStr)
+)
+
+// FileSink write logs to a file, file is rotated by a RotatePolicy,
+// by default every day and when MaxLine or MaxSize is reached
+// rotated files are renamed to name_20060102_N.ext, the day is the day current file was created
+type FileSink struct {
+	path    string // log file path
+	level   int
+	encoder Encoder
+	policy  RotatePolicy
+	clock   Clock
+	fs      FileSystem
+	leftDay int
+
+	mutex   *sync.Mutex // guards fields below
+	file    File        // nil if file can not be opened, it is opened again on next write
+	state   RotateState
+	checked time.Time // last time file was checked for existence
+	failed  time.Time // last time file could not be renamed, rotation is retried at most once a second
+	closed  bool
+	done    chan struct{}
+}
+
+// NewFileSink return a file sink, Level, MaxLine, MaxSize, Mode, Compress, LeftDay, Encoder, Rotate, Clock and FS of option work
+func NewFileSink(path string, option *LoggerOption) (*FileSink, error) {
+	filePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	sink := &FileSink{
+		path:    filePath,
+		level:   Trace,
+		clock:   systemClock{},
+		fs:      osFileSystem{},
+		leftDay: 3,
+		mutex:   new(sync.Mutex),
+		done:    make(chan struct{}),
+	}
+	mode := Std
+	maxLine, maxSize := 0, 0
+	compress := false
+	if option != nil {
+		maxLine, maxSize = option.MaxLine, option.MaxSize
+		if option.Level > 0 {
+			sink.level = option.Level
+		}
+		if option.Mode > 0 {
+			mode = option.Mode
+		}
+		if option.LeftDay > 0 {
+			sink.leftDay = option.LeftDay
+		}
+		if option.Clock != nil {
+			sink.clock = option.Clock
+		}
+		if option.FS != nil {
+			sink.fs = option.FS
+		}
+		sink.encoder = option.Encoder
+		sink.policy = option.Rotate
+		compress = option.Compress
+	}
+	if sink.policy == nil {
+		sink.policy = AnyPolicy(DailyPolicy(), SizePolicy(int64(maxSize)), LinePolicy(maxLine))
+	}
+	if err = sink.open(sink.clock.Now()); err != nil {
+		return nil, err
+	}
+	if sink.encoder == nil {
+		f, _ := sink.file.(*os.File)
+		sink.encoder = &TextEncoder{Mode: mode, Color: isTerminal(f)}
+	}
+	if compress {
+		go sink.compressLoop()
+	}
+	return sink, nil
+}
+
+// Write write an entry
+func (sink *FileSink) Write(e *Entry) error {
+	if e.Level < sink.level {
+		return nil
+	}
+	return sink.write(append(sink.encoder.Encode(e), '\n'), 1)
+}
+
+// WriteBatch write entries with one write call, used by AsyncSink
+func (sink *FileSink) WriteBatch(es []*Entry) error {
+	buf := make([]byte, 0, 128*len(es))
+	lines := 0
+	for _, e := range es {
+		if e.Level < sink.level {
+			continue
+		}
+		buf = append(buf, sink.encoder.Encode(e)...)
+		buf = append(buf, '\n')
+		lines++
+	}
+	if lines == 0 {
+		return nil
+	}
+	return sink.write(buf, lines)
+}
+
+// write write b which has lines lines, file is rotated before b is written if policy says so
+func (sink *FileSink) write(b []byte, lines int) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return ErrSinkClosed
+	}
+	now := sink.clock.Now()
+	if err := sink.check(now); err != nil {
+		return err
+	}
+	var rotateErr error
+	if sink.canRotate(now) && sink.policy.ShouldRotate(&sink.state, now, len(b)) {
+		if rotateErr = sink.rotate(now); sink.file == nil {
+			return rotateErr
+		}
+	}
+	n, err := sink.file.Write(b)
+	sink.state.Size += int64(n)
+	sink.state.Lines += lines
+	if err == nil {
+		err = rotateErr
+	}
+	return err
+}
+
+// check open file again if it can not be opened last time or it is removed,
+// existence of file is checked at most once a second
+func (sink *FileSink) check(now time.Time) error {
+	if sink.file != nil {
+		if now.Sub(sink.checked) < time.Second && !now.Before(sink.checked) {
+			return nil
+		}
+		sink.checked = now
+		if _, err := sink.fs.Stat(sink.path); !os.IsNotExist(err) {
+			return nil
+		}
+		sink.file.Close()
+		sink.file = nil
+	}
+	return sink.open(now)
+}
+
+// open open or create log file for appending, state of an existing file is restored from its size and modification time
+// lines of an existing file are not counted
+func (sink *FileSink) open(now time.Time) error {
+	if err := sink.fs.MkdirAll(filepath.Dir(sink.path), 0755); err != nil {
+		return err
+	}
+	f, err := sink.fs.OpenFile(sink.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	sink.file = f
+	sink.checked = now
+	sink.state = RotateState{Created: now}
+	if fi, err := sink.fs.Stat(sink.path); err == nil && fi.Size() > 0 {
+		sink.state.Size = fi.Size()
+		sink.state.Created = fi.ModTime()
+	}
+	return nil
+}
+
+// rotate rename current file and open a new one, an empty file is reused
+func (sink *FileSink) rotate(now time.Time) error {
+	if sink.state.Size == 0 && sink.state.Lines == 0 {
+		sink.state.Created = now
+		return nil
+	}
+	state := sink.state
+	err := sink.file.Close()
+	sink.file = nil
+	if err == nil {
+		err = sink.rename(sink.state.Created.Format("20060102"))
+	}
+	if e := sink.open(now); e != nil {
+		return e
+	}
+	if err != nil {
+		// file is not renamed, keep writing to it and counting its size, retry later
+		sink.state = state
+		sink.failed = now
+	}
+	return err
+}
+
+func (sink *FileSink) canRotate(now time.Time) bool {
+	return sink.failed.IsZero() || now.Sub(sink.failed) >= time.Second || now.Before(sink.failed)
+}
+
+// rename rename log file to name_day_N.ext, N is 1 + the largest N of that day
+func (sink *FileSink) rename(day string) error {
+	dir := filepath.Dir(sink.path)   // /data/log/xxx.log
+	name := filepath.Base(sink.path) // xxx.log
+	namePrefix := name               // xxx
+	suffix := ""                     // .log
+	containDot := false
+	if strings.Contains(name, ".") {
+		containDot = true
+		i := strings.Index(name, ".")
+		namePrefix = name[0:i]
+		suffix = name[i:]
+	}
+	fl, err := sink.fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string) // names: xxx_20170908_1.log   xxx_20170908_2.log   xxx.log
+	for _, f := range fl {
+		names[f.Name()] = ""
+	}
+	r := 1
+	for i := 1; i <= len(fl); i++ {
+		if _, contains := names[namePrefix+"_"+day+"_"+strconv.Itoa(i)+suffix]; contains {
+			if i >= r {
+				r = i + 1
+			}
+		}
+	}
+	// rename old file with suffix
+	var newName string
+	if containDot {
+		newName = dir + string(filepath.Separator) + namePrefix + "_" + day + "_" + strconv.Itoa(r) + suffix
+	} else {
+		newName = dir + string(filepath.Separator) + name + "_" + day + "_" + strconv.Itoa(r)
+	}
+	return sink.fs.Rename(sink.path, newName)
+}
+
+// Close close log file, logs after Close are discarded
+func (sink *FileSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+	close(sink.done)
+	if sink.file == nil {
+		return nil
+	}
+	return sink.file.Close()
+}
+
+func (sink *FileSink) compressLoop() {
+	t := time.NewTicker(24 * time.Hour)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			sink.compressLog()
+		case <-sink.done:
+			return
+		}
+	}
+}
+
+// compressLog zip rotated files older than LeftDay days, one zip file a day, files are removed if they are zipped
+func (sink *FileSink) compressLog() {
+	fileName := filepath.Base(sink.path)
+	dir := filepath.Dir(sink.path)
+	namePrefix := fileName
+	if strings.Contains(fileName, ".") {
+		i := strings.Index(fileName, ".")
+		namePrefix = fileName[0:i]
+	}
+	for day, files := range sink.getEarlyFile() {
+		zipName := dir + string(filepath.Separator) + namePrefix + day + ".zip"
+		if sink.zipFiles(zipName, files) == nil {
+			for _, s := range files {
+				sink.fs.Remove(s)
+			}
+		}
+	}
+}
+
+func (sink *FileSink) zipFiles(zipName string, files []string) error {
+	d, err := sink.fs.OpenFile(zipName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := zip.NewWriter(d)
+	for _, s := range files {
+		if err == nil {
+			err = sink.compress(s, w)
+		}
+	}
+	if e := w.Close(); err == nil {
+		err = e
+	}
+	if e := d.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (sink *FileSink) compress(path string, zw *zip.Writer) error {
+	info, err := sink.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Method = zip.Deflate
+	writer, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	file, err := sink.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+func (sink *FileSink) getEarlyFile() map[string][]string {
+	result := make(map[string][]string)
+	dayStr := sink.clock.Now().AddDate(0, 0, -sink.leftDay+1).Format("20060102") + " 00:00:00"
+	pointTime, _ := time.ParseInLocation("20060102 15:04:05", dayStr, time.Local)
+	point := pointTime.Unix()
+	dir := filepath.Dir(sink.path)
+	fileName := filepath.Base(sink.path)
+	filePrefix := fileName
+	fileSuffix := ""
+	if strings.Contains(fileName, ".") {
+		i := strings.Index(fileName, ".")
+		filePrefix = fileName[0:i]
+		fileSuffix = regexp.QuoteMeta(fileName[i:])
+	}
+	fl, err := sink.fs.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var fileRegexpStr = `^` + regexp.QuoteMeta(filePrefix) + `_\d{8}_\d+` + fileSuffix + `$`
+	var logRegex = regexp.MustCompile(fileRegexpStr)
+	for _, f := range fl {
+		if logRegex.MatchString(f.Name()) {
+			loc := dateRexexp.FindStringIndex(f.Name())
+			day := f.Name()[loc[0]+1 : loc[1]-1]
+			fileTime, _ := time.ParseInLocation("20060102", day, time.Local)
+			if fileTime.Unix() < point {
+				result[day] = append(result[day], dir+string(filepath.Separator)+f.Name())
+			}
+		}
+	}
+	return result
+}
+
+func panicErr(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
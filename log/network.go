@@ -0,0 +1,119 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package log
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const netTimeout = 5 * time.Second
+
+// connWriter write lines to a connection, it reconnects once if a write fails
+type connWriter struct {
+	dial   func() (net.Conn, error)
+	conn   net.Conn
+	mutex  *sync.Mutex
+	closed bool
+}
+
+func (a *connWriter) write(b []byte) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return ErrSinkClosed
+	}
+	var err error
+	for i := 0; i < 2; i++ {
+		if a.conn == nil {
+			if a.conn, err = a.dial(); err != nil {
+				a.conn = nil
+				return err
+			}
+		}
+		a.conn.SetWriteDeadline(time.Now().Add(netTimeout))
+		if _, err = a.conn.Write(b); err == nil {
+			return nil
+		}
+		a.conn.Close()
+		a.conn = nil
+	}
+	return err
+}
+
+func (a *connWriter) close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	if a.conn != nil {
+		return a.conn.Close()
+	}
+	return nil
+}
+
+// NetworkSink ship logs to a tcp or udp server, one entry per line
+type NetworkSink struct {
+	w       *connWriter
+	level   int
+	encoder Encoder
+}
+
+// NewNetworkSink return a sink shipping logs to addr, network is tcp or udp
+// connection is redialed if it is broken
+func NewNetworkSink(network, addr string, option *SinkOption) (*NetworkSink, error) {
+	w := &connWriter{dial: func() (net.Conn, error) {
+		return net.DialTimeout(network, addr, netTimeout)
+	}, mutex: new(sync.Mutex)}
+	var err error
+	if w.conn, err = w.dial(); err != nil {
+		return nil, err
+	}
+	sink := &NetworkSink{w: w}
+	if option != nil {
+		sink.level = option.Level
+		sink.encoder = option.Encoder
+	}
+	if sink.encoder == nil {
+		sink.encoder = &TextEncoder{}
+	}
+	return sink, nil
+}
+
+// Write write an entry
+func (sink *NetworkSink) Write(e *Entry) error {
+	if e.Level < sink.level {
+		return nil
+	}
+	return sink.w.write(append(sink.encoder.Encode(e), '\n'))
+}
+
+// Close close connection
+func (sink *NetworkSink) Close() error {
+	return sink.w.close()
+}
@@ -0,0 +1,148 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package log
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// RotateState state of current log file
+type RotateState struct {
+	Created time.Time // time file was created or opened
+	Size    int64
+	Lines   int
+}
+
+// RotatePolicy decide whether log file is rotated before a line of n bytes is written
+type RotatePolicy interface {
+	ShouldRotate(state *RotateState, now time.Time, n int) bool
+}
+
+// RotateFunc adapter of functions as RotatePolicy
+type RotateFunc func(state *RotateState, now time.Time, n int) bool
+
+// ShouldRotate call f
+func (f RotateFunc) ShouldRotate(state *RotateState, now time.Time, n int) bool {
+	return f(state, now, n)
+}
+
+// SizePolicy rotate if file would exceed max bytes, a line larger than max is written to an empty file
+func SizePolicy(max int64) RotatePolicy {
+	return RotateFunc(func(state *RotateState, now time.Time, n int) bool {
+		return max > 0 && state.Size > 0 && state.Size+int64(n) > max
+	})
+}
+
+// LinePolicy rotate if file has max lines
+func LinePolicy(max int) RotatePolicy {
+	return RotateFunc(func(state *RotateState, now time.Time, n int) bool {
+		return max > 0 && state.Lines >= max
+	})
+}
+
+// DailyPolicy rotate at midnight
+func DailyPolicy() RotatePolicy {
+	return RotateFunc(func(state *RotateState, now time.Time, n int) bool {
+		return now.Format("20060102") != state.Created.Format("20060102")
+	})
+}
+
+// HourlyPolicy rotate on the hour
+func HourlyPolicy() RotatePolicy {
+	return RotateFunc(func(state *RotateState, now time.Time, n int) bool {
+		return now.Format("2006010215") != state.Created.Format("2006010215")
+	})
+}
+
+// AnyPolicy rotate if any of policies says so
+// e.g.
+//     log.AnyPolicy(log.HourlyPolicy(), log.SizePolicy(100<<20))
+func AnyPolicy(policies ...RotatePolicy) RotatePolicy {
+	return RotateFunc(func(state *RotateState, now time.Time, n int) bool {
+		for _, p := range policies {
+			if p != nil && p.ShouldRotate(state, now, n) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Clock source of time, replace it in tests
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// File an opened file
+type File interface {
+	io.Reader
+	io.Writer
+	io.Closer
+}
+
+// FileSystem file operations used by FileSink, replace it in tests
+type FileSystem interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	MkdirAll(path string, perm os.FileMode) error
+	ReadDir(dirname string) ([]os.FileInfo, error)
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFileSystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dirname)
+}
@@ -0,0 +1,161 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package log
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrSinkClosed sink has been closed
+var ErrSinkClosed = errors.New("log: sink closed")
+
+// Sink destination of logs
+type Sink interface {
+	// Write write an entry, entries below level of sink are ignored
+	Write(e *Entry) error
+	Close() error
+}
+
+// SinkOption sink options
+type SinkOption struct {
+	Level   int     // min level written by sink
+	Encoder Encoder // TextEncoder if it is nil
+}
+
+// WriterSink write logs to an io.Writer, one entry per line
+type WriterSink struct {
+	w       io.Writer
+	level   int
+	encoder Encoder
+	mutex   *sync.Mutex
+	closed  bool
+}
+
+// NewWriterSink return a sink writing to w, w is closed by Close if it is an io.Closer
+func NewWriterSink(w io.Writer, option *SinkOption) *WriterSink {
+	sink := &WriterSink{w: w, mutex: new(sync.Mutex)}
+	if option != nil {
+		sink.level = option.Level
+		sink.encoder = option.Encoder
+	}
+	if sink.encoder == nil {
+		sink.encoder = &TextEncoder{}
+	}
+	return sink
+}
+
+// NewConsoleSink return a sink writing to os.Stdout or os.Stderr, TextEncoder is colored if f is a terminal
+func NewConsoleSink(f *os.File, option *SinkOption) *WriterSink {
+	o := SinkOption{}
+	if option != nil {
+		o = *option
+	}
+	if o.Encoder == nil {
+		o.Encoder = &TextEncoder{Color: isTerminal(f)}
+	}
+	return NewWriterSink(nopCloser{f}, &o)
+}
+
+// Write write an entry
+func (sink *WriterSink) Write(e *Entry) error {
+	if e.Level < sink.level {
+		return nil
+	}
+	b := append(sink.encoder.Encode(e), '\n')
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return ErrSinkClosed
+	}
+	_, err := sink.w.Write(b)
+	return err
+}
+
+// Close close writer if it is an io.Closer
+func (sink *WriterSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+	if c, ok := sink.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// nopCloser console must not be closed
+type nopCloser struct {
+	io.Writer
+}
+
+// MultiSink fan out entries to sinks
+type MultiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink return a sink writing to every sink, e.g. a file and stderr
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// Write write entry to every sink, the first error is returned
+func (sink *MultiSink) Write(e *Entry) error {
+	var err error
+	for _, s := range sink.sinks {
+		if werr := s.Write(e); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// Flush flush every asynchronous sink
+func (sink *MultiSink) Flush() error {
+	var err error
+	for _, s := range sink.sinks {
+		if f, ok := s.(interface{ Flush() error }); ok {
+			if ferr := f.Flush(); ferr != nil && err == nil {
+				err = ferr
+			}
+		}
+	}
+	return err
+}
+
+// Close close every sink, the first error is returned
+func (sink *MultiSink) Close() error {
+	var err error
+	for _, s := range sink.sinks {
+		if cerr := s.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// syslog facilities
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+)
+
+var syslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslog severities of levels
+var syslogSeverities = []int{7, 6, 4, 3, 2}
+
+// SyslogSink write logs to local syslog daemon through unix socket
+type SyslogSink struct {
+	w        *connWriter
+	tag      string
+	facility int
+	level    int
+	encoder  Encoder
+}
+
+// NewSyslogSink return a syslog sink, tag is usually name of program
+// entries are encoded by LogfmtEncoder unless Encoder of option is set
+func NewSyslogSink(tag string, facility int, option *SinkOption) (*SyslogSink, error) {
+	w := &connWriter{dial: dialSyslog, mutex: new(sync.Mutex)}
+	var err error
+	if w.conn, err = w.dial(); err != nil {
+		return nil, err
+	}
+	sink := &SyslogSink{w: w, tag: tag, facility: facility}
+	if option != nil {
+		sink.level = option.Level
+		sink.encoder = option.Encoder
+	}
+	if sink.encoder == nil {
+		sink.encoder = &LogfmtEncoder{}
+	}
+	return sink, nil
+}
+
+func dialSyslog() (net.Conn, error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range syslogPaths {
+			if conn, err := net.DialTimeout(network, path, netTimeout); err == nil {
+				return conn, nil
+			}
+		}
+	}
+	return nil, errors.New("log: unix syslog delivery error")
+}
+
+// Write write an entry
+func (sink *SyslogSink) Write(e *Entry) error {
+	if e.Level < sink.level {
+		return nil
+	}
+	severity := 7
+	if e.Level >= Trace && e.Level <= Fatal {
+		severity = syslogSeverities[e.Level]
+	}
+	header := fmt.Sprintf("<%d>%s %s[%d]: ", sink.facility*8+severity, e.Time.Format(time.Stamp), sink.tag, os.Getpid())
+	return sink.w.write(append([]byte(header), append(sink.encoder.Encode(e), '\n')...))
+}
+
+// Close close connection
+func (sink *SyslogSink) Close() error {
+	return sink.w.close()
+}
@@ -0,0 +1,334 @@
+/*
+MIT License
+
+Copyright (c) 2018 Frank Lee
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package metrics counters, gauges and histograms exposed in prometheus text format
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+
+	labelSeparator = "\xff"
+)
+
+// DefaultBuckets default histogram buckets, in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultRegistry registry used by httpserver, orm and job if no other one is given
+var DefaultRegistry = NewRegistry()
+
+type metric interface {
+	name() string
+	kind() string
+	write(b *bytes.Buffer)
+}
+
+// Registry a set of metrics
+type Registry struct {
+	mutex     *sync.Mutex
+	metrics   map[string]metric
+	names     []string
+	onCollect []func()
+}
+
+// NewRegistry return an empty registry
+func NewRegistry() *Registry {
+	return &Registry{mutex: new(sync.Mutex), metrics: make(map[string]metric)}
+}
+
+// register add m, or return the registered one if a metric of the same name and kind exists
+// it panics if name is registered with another kind
+func (a *Registry) register(m metric) metric {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if old, c := a.metrics[m.name()]; c {
+		if old.kind() != m.kind() {
+			panic("metrics: " + m.name() + " is registered as " + old.kind())
+		}
+		return old
+	}
+	a.metrics[m.name()] = m
+	a.names = append(a.names, m.name())
+	sort.Strings(a.names)
+	return m
+}
+
+// OnCollect register f which is called before metrics are written,
+// it can be used to update gauges from other sources
+func (a *Registry) OnCollect(f func()) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.onCollect = append(a.onCollect, f)
+}
+
+// WriteText write all metrics in prometheus text format
+func (a *Registry) WriteText(w io.Writer) error {
+	a.mutex.Lock()
+	hooks := append([]func(){}, a.onCollect...)
+	a.mutex.Unlock()
+	for _, f := range hooks {
+		f()
+	}
+	a.mutex.Lock()
+	ms := make([]metric, 0, len(a.names))
+	for _, n := range a.names {
+		ms = append(ms, a.metrics[n])
+	}
+	a.mutex.Unlock()
+	b := new(bytes.Buffer)
+	for _, m := range ms {
+		m.write(b)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Counter register a counter, labels are names of labels
+func (a *Registry) Counter(name, help string, labels ...string) *Counter {
+	return a.register(&Counter{newVec(name, help, counterType, labels)}).(*Counter)
+}
+
+// Gauge register a gauge, labels are names of labels
+func (a *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return a.register(&Gauge{newVec(name, help, gaugeType, labels)}).(*Gauge)
+}
+
+// GaugeFunc register a gauge whose value is f()
+func (a *Registry) GaugeFunc(name, help string, f func() float64) {
+	g := a.Gauge(name, help)
+	a.OnCollect(func() {
+		g.Set(f())
+	})
+}
+
+// Histogram register a histogram, DefaultBuckets is used if buckets is empty
+func (a *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	bs := append([]float64{}, buckets...)
+	sort.Float64s(bs)
+	return a.register(&Histogram{vec: newVec(name, help, histogramType, labels), buckets: bs}).(*Histogram)
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64 // histogram only
+	count       uint64
+}
+
+type vec struct {
+	n      string
+	help   string
+	typ    string
+	labels []string
+	mutex  *sync.Mutex
+	series map[string]*series
+}
+
+func newVec(name, help, typ string, labels []string) vec {
+	return vec{n: name, help: help, typ: typ, labels: labels, mutex: new(sync.Mutex), series: make(map[string]*series)}
+}
+
+func (a *vec) name() string {
+	return a.n
+}
+
+func (a *vec) kind() string {
+	return a.typ
+}
+
+// get must be called with mutex held
+func (a *vec) get(labelValues []string) *series {
+	if len(labelValues) != len(a.labels) {
+		panic("metrics: " + a.n + " expects " + strconv.Itoa(len(a.labels)) + " label values")
+	}
+	k := strings.Join(labelValues, labelSeparator)
+	s, c := a.series[k]
+	if !c {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+		a.series[k] = s
+	}
+	return s
+}
+
+func (a *vec) sorted() []*series {
+	ks := make([]string, 0, len(a.series))
+	for k := range a.series {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	ss := make([]*series, 0, len(ks))
+	for _, k := range ks {
+		ss = append(ss, a.series[k])
+	}
+	return ss
+}
+
+func (a *vec) writeHeader(b *bytes.Buffer) {
+	b.WriteString("# HELP " + a.n + " " + escape(a.help, false) + "\n")
+	b.WriteString("# TYPE " + a.n + " " + a.typ + "\n")
+}
+
+func (a *vec) write(b *bytes.Buffer) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.writeHeader(b)
+	for _, s := range a.sorted() {
+		b.WriteString(a.n + labelString(a.labels, s.labelValues, "", "") + " " + formatFloat(s.value) + "\n")
+	}
+}
+
+// Counter a value that only goes up
+type Counter struct {
+	vec
+}
+
+// Inc add 1
+func (a *Counter) Inc(labelValues ...string) {
+	a.Add(1, labelValues...)
+}
+
+// Add add v, v must not be negative
+func (a *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + a.n + " can not decrease")
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.get(labelValues).value += v
+}
+
+// Gauge a value that can go up and down
+type Gauge struct {
+	vec
+}
+
+// Set set value to v
+func (a *Gauge) Set(v float64, labelValues ...string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.get(labelValues).value = v
+}
+
+// Add add v, v can be negative
+func (a *Gauge) Add(v float64, labelValues ...string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.get(labelValues).value += v
+}
+
+// Inc add 1
+func (a *Gauge) Inc(labelValues ...string) {
+	a.Add(1, labelValues...)
+}
+
+// Dec sub 1
+func (a *Gauge) Dec(labelValues ...string) {
+	a.Add(-1, labelValues...)
+}
+
+// Histogram samples observations in buckets
+type Histogram struct {
+	vec
+	buckets []float64
+}
+
+// Observe add an observation
+func (a *Histogram) Observe(v float64, labelValues ...string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	s := a.get(labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(a.buckets))
+	}
+	for i, upper := range a.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+func (a *Histogram) write(b *bytes.Buffer) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.writeHeader(b)
+	for _, s := range a.sorted() {
+		for i, upper := range a.buckets {
+			b.WriteString(a.n + "_bucket" + labelString(a.labels, s.labelValues, "le", formatFloat(upper)) + " " + strconv.FormatUint(s.counts[i], 10) + "\n")
+		}
+		b.WriteString(a.n + "_bucket" + labelString(a.labels, s.labelValues, "le", "+Inf") + " " + strconv.FormatUint(s.count, 10) + "\n")
+		b.WriteString(a.n + "_sum" + labelString(a.labels, s.labelValues, "", "") + " " + formatFloat(s.value) + "\n")
+		b.WriteString(a.n + "_count" + labelString(a.labels, s.labelValues, "", "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+func labelString(labels, values []string, extraLabel, extraValue string) string {
+	if len(labels) == 0 && extraLabel == "" {
+		return ""
+	}
+	ps := make([]string, 0, len(labels)+1)
+	for i, l := range labels {
+		ps = append(ps, l+`="`+escape(values[i], true)+`"`)
+	}
+	if extraLabel != "" {
+		ps = append(ps, extraLabel+`="`+extraValue+`"`)
+	}
+	return "{" + strings.Join(ps, ",") + "}"
+}
+
+func escape(s string, quote bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	if quote {
+		s = strings.Replace(s, `"`, `\"`, -1)
+	}
+	return s
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
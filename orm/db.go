@@ -34,6 +34,7 @@ import (
 	"time"
 
 	"github.com/FrankLeeC/Aurora/log"
+	"github.com/FrankLeeC/Aurora/metrics"
 )
 
 var (
@@ -93,6 +94,58 @@ func RegisterDataSource(name, s string, o *Option) error {
 	return nil
 }
 
+// DB get *sql.DB of a registered data source
+func DB(dataSource string) (*sql.DB, error) {
+	return getConn(dataSource)
+}
+
+// Ping ping all registered data sources
+// it returns *PingErr of the first unreachable data source
+func Ping() error {
+	for name, db := range dbMap {
+		if err := db.Ping(); err != nil {
+			return &PingErr{dataSource: name, err: err}
+		}
+	}
+	return nil
+}
+
+// Close close all registered data sources
+// it returns the first error encountered
+func Close() error {
+	var err error
+	for name, db := range dbMap {
+		if e := db.Close(); e != nil {
+			ormLog.Info("close mysql datasource: %s, error: %s", name, e.Error())
+			if err == nil {
+				err = e
+			}
+		}
+		delete(dbMap, name)
+		delete(datasource, name)
+	}
+	return err
+}
+
+// RegisterMetrics register gauges of connection pools in registry r, labeled by data source name
+func RegisterMetrics(r *metrics.Registry) {
+	open := r.Gauge("orm_open_connections", "Count of established connections.", "datasource")
+	inUse := r.Gauge("orm_in_use_connections", "Count of connections in use.", "datasource")
+	idle := r.Gauge("orm_idle_connections", "Count of idle connections.", "datasource")
+	waitCount := r.Gauge("orm_wait_count", "Count of connections waited for.", "datasource")
+	waitDuration := r.Gauge("orm_wait_duration_seconds", "Time blocked waiting for a new connection in seconds.", "datasource")
+	r.OnCollect(func() {
+		for name, db := range dbMap {
+			st := db.Stats()
+			open.Set(float64(st.OpenConnections), name)
+			inUse.Set(float64(st.InUse), name)
+			idle.Set(float64(st.Idle), name)
+			waitCount.Set(float64(st.WaitCount), name)
+			waitDuration.Set(st.WaitDuration.Seconds(), name)
+		}
+	})
+}
+
 // sql.DB is a pool
 func getDatabase(s string) (*sql.DB, error) {
 	return sql.Open("mysql", s)
@@ -56,6 +56,16 @@ func (err *DunplicateDataSourceErr) Error() string {
 	return "duplicated data source:" + err.dataSource
 }
 
+// PingErr data source is unreachable
+type PingErr struct {
+	dataSource string
+	err        error
+}
+
+func (err *PingErr) Error() string {
+	return "ping data source:" + err.dataSource + " error:" + err.err.Error()
+}
+
 // DunplicateSQLIDErr dunplicate sql id
 type DunplicateSQLIDErr struct {
 	sqlID string
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+type nop struct{}
+
+func (a *nop) Before(rsp *httpserver.Response, req *httpserver.Request) bool { return true }
+func (a *nop) After(rsp *httpserver.Response, req *httpserver.Request)       {}
+
+func ok(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+	return 200, nil
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func get(t *testing.T, url string) (int, []byte) {
+	var rsp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		if rsp, err = http.Get(url); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	b, _ := ioutil.ReadAll(rsp.Body)
+	return rsp.StatusCode, b
+}
+
+func TestAdmin(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Route("/a", ok)
+	s.Filter("/a", &nop{})
+	s.Host("api.example.com").DynamicRoute("/users/{id}", ok)
+	healthy := true
+	s.AddCheck("db", func() error {
+		if !healthy {
+			return errors.New("down")
+		}
+		return nil
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	port := freePort(t)
+	s.Admin(port)
+	defer s.Shutdown()
+	base := "http://127.0.0.1:" + strconv.Itoa(port)
+
+	if code, b := get(t, base+"/healthz"); code != 200 || string(b) != "ok" {
+		t.Errorf("healthz: %d %s", code, b)
+	}
+	if code, _ := get(t, base+"/readyz"); code != 200 {
+		t.Errorf("readyz: %d", code)
+	}
+	healthy = false
+	if code, b := get(t, base+"/readyz"); code != 503 {
+		t.Errorf("readyz with failed check: %d %s", code, b)
+	}
+
+	_, b := get(t, base+"/debug/routes")
+	var routes []*httpserver.RouteInfo
+	if err := json.Unmarshal(b, &routes); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, r := range routes {
+		found[r.Host+r.Pattern] = true
+		if r.Pattern == "/a" && (len(r.Filters) != 1 || r.Filters[0] != "*test.nop") {
+			t.Errorf("unexpected filters of /a: %v", r.Filters)
+		}
+	}
+	if !found["/a"] || !found["api.example.com/users/{id}"] {
+		t.Errorf("unexpected routes %s", b)
+	}
+	for _, p := range []string{"/debug/pprof/", "/debug/pprof/symbol", "/debug/pprof/heap?debug=1", "/debug/pprof/cmdline"} {
+		if code, _ := get(t, base+p); code != 200 {
+			t.Errorf("%s: %d", p, code)
+		}
+	}
+
+	// admin binds loopback only
+	if ips := nonLoopback(); len(ips) > 0 {
+		if _, err := net.DialTimeout("tcp", net.JoinHostPort(ips[0], strconv.Itoa(port)), time.Second); err == nil {
+			t.Errorf("admin is reachable on %s", ips[0])
+		}
+	}
+}
+
+func nonLoopback() []string {
+	ips := make([]string, 0)
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && !n.IP.IsLoopback() && n.IP.To4() != nil {
+			ips = append(ips, n.IP.String())
+		}
+	}
+	return ips
+}
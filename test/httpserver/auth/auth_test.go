@@ -0,0 +1,140 @@
+package test
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/auth"
+)
+
+func serve(t *testing.T, path string, f httpserver.Filter) string {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Filter(path, f)
+	s.Route(path, func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Write([]byte(auth.Get(req).Name))
+		return 200, nil
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	return "http://" + l.Addr().String() + path
+}
+
+func do(t *testing.T, r *http.Request) int {
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	return rsp.StatusCode
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+	j, err := auth.NewJWT(&auth.JWTOption{Secret: secret, Issuer: "aurora"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := auth.SignHS256(map[string]interface{}{"sub": "frank", "iss": "aurora", "exp": time.Now().Add(time.Minute).Unix()}, secret)
+	if claims, err := j.Verify(token); err != nil || claims["sub"] != "frank" {
+		t.Errorf("valid token refused: %v", err)
+	}
+	expired, _ := auth.SignHS256(map[string]interface{}{"iss": "aurora", "exp": time.Now().Add(-time.Minute).Unix()}, secret)
+	if _, err := j.Verify(expired); err != auth.ErrTokenExpired {
+		t.Errorf("expired token: %v", err)
+	}
+	forged, _ := auth.SignHS256(map[string]interface{}{"iss": "aurora"}, []byte("other"))
+	if _, err := j.Verify(forged); err != auth.ErrTokenSignature {
+		t.Errorf("forged token: %v", err)
+	}
+	j, _ = auth.NewJWT(&auth.JWTOption{Secret: secret, Audience: "api"})
+	if _, err := j.Verify(token); err != auth.ErrTokenClaims {
+		t.Errorf("token without audience: %v", err)
+	}
+	for _, o := range []*auth.JWTOption{nil, {}, {Secret: secret, PublicKey: &rsa.PublicKey{}}} {
+		if _, err := auth.NewJWT(o); err != auth.ErrJWTOption {
+			t.Errorf("option %v: expect ErrJWTOption, got %v", o, err)
+		}
+	}
+}
+
+func TestBasicAndAuthorize(t *testing.T) {
+	b := auth.NewBasic("test", func(user, password string) bool {
+		return auth.SecureCompare(password, "pass")
+	})
+	b.Authorize = func(p *auth.Principal, r *httpserver.Request) bool {
+		return p.Name == "frank"
+	}
+	u := serve(t, "/basic", b)
+	cases := []struct {
+		user, password string
+		code           int
+	}{{"", "", 401}, {"frank", "wrong", 401}, {"lee", "pass", 403}, {"frank", "pass", 200}}
+	for _, c := range cases {
+		r, _ := http.NewRequest("GET", u, nil)
+		if c.user != "" {
+			r.SetBasicAuth(c.user, c.password)
+		}
+		if code := do(t, r); code != c.code {
+			t.Errorf("%s:%s expect %d, got %d", c.user, c.password, c.code, code)
+		}
+	}
+}
+
+func TestHMACReplay(t *testing.T) {
+	secret := []byte("secret")
+	u := serve(t, "/hmac", auth.NewHMAC(func(key string) ([]byte, bool) {
+		return secret, key == "client"
+	}))
+	body := []byte(`{"a":1}`)
+	r, _ := http.NewRequest("POST", u, bytes.NewReader(body))
+	auth.SignRequest(r, "client", secret, body)
+	if code := do(t, r); code != 200 {
+		t.Fatalf("signed request expect 200, got %d", code)
+	}
+	replay, _ := http.NewRequest("POST", u, bytes.NewReader(body))
+	replay.Header = r.Header
+	if code := do(t, replay); code != 401 {
+		t.Errorf("replayed request expect 401, got %d", code)
+	}
+	tampered, _ := http.NewRequest("POST", u, bytes.NewReader([]byte(`{"a":2}`)))
+	auth.SignRequest(tampered, "client", secret, body)
+	if code := do(t, tampered); code != 401 {
+		t.Errorf("tampered request expect 401, got %d", code)
+	}
+}
+
+func TestHMACBodyLimit(t *testing.T) {
+	secret := []byte("secret")
+	h := auth.NewHMAC(func(key string) ([]byte, bool) {
+		return secret, true
+	})
+	h.MaxBodySize = 16
+	u := serve(t, "/hmac", h)
+	body := []byte(strings.Repeat("a", 17))
+	r, _ := http.NewRequest("POST", u, bytes.NewReader(body))
+	auth.SignRequest(r, "client", secret, body)
+	if code := do(t, r); code != 413 {
+		t.Errorf("large body expect 413, got %d", code)
+	}
+	r, _ = http.NewRequest("POST", u, bytes.NewReader(body))
+	r.ContentLength = -1 // chunked
+	auth.SignRequest(r, "client", secret, body)
+	if code := do(t, r); code != 413 {
+		t.Errorf("large chunked body expect 413, got %d", code)
+	}
+	r, _ = http.NewRequest("POST", u, bytes.NewReader(body[:16]))
+	auth.SignRequest(r, "client", secret, body[:16])
+	if code := do(t, r); code != 200 {
+		t.Errorf("body within limit expect 200, got %d", code)
+	}
+}
@@ -0,0 +1,167 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/cache"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+func TestCacheFilter(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "cache")
+	defer os.RemoveAll(dir)
+	fs, err := cache.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, store := range []cache.Store{cache.NewLRUStore(10), fs} {
+		calls := 0
+		s := httpserver.NewHTTPServerWithOption(nil)
+		ts := testserver.New(t, s)
+		c := cache.NewFilter(store, &cache.Option{Vary: []string{"Accept"}})
+		s.DynamicFilter("/users/{id}", c)
+		s.DynamicRoute("/users/{id}", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+			calls++
+			cache.Tag(req, "user:"+req.GetDynamicParam("id"))
+			if req.URL.Query().Get("private") != "" {
+				rsp.Header().Set("Cache-Control", "private")
+			}
+			rsp.Write([]byte("user " + req.GetDynamicParam("id")))
+			return 200, nil
+		})
+
+		etag := ts.GET("/users/1").Expect(200).Header("X-Cache", "MISS").Then().Header.Get("ETag")
+		ts.GET("/users/1").Expect(200).Header("X-Cache", "HIT").Body("user 1")
+		ts.GET("/users/1").WithHeader("If-None-Match", etag).Expect(304).Body("")
+		ts.GET("/users/1").WithHeader("Accept", "text/plain").Expect(200).Header("X-Cache", "MISS")
+		if calls != 2 {
+			t.Errorf("%T: expect 2 calls, got %d", store, calls)
+		}
+		c.Invalidate("user:1")
+		ts.GET("/users/1").Expect(200).Header("X-Cache", "MISS")
+		ts.GET("/users/2?private=1").Expect(200).Header("X-Cache", "")
+		ts.GET("/users/2?private=1").Expect(200).Header("X-Cache", "")
+		if calls != 5 {
+			t.Errorf("%T: expect 5 calls, got %d", store, calls)
+		}
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	s := cache.NewLRUStore(2)
+	exp := time.Now().Add(time.Minute)
+	s.Set("a", &cache.Entry{Expires: exp})
+	s.Set("b", &cache.Entry{Expires: exp})
+	s.Get("a")
+	s.Set("c", &cache.Entry{Expires: exp})
+	if e, _ := s.Get("b"); e != nil {
+		t.Error("least recently used entry b should be evicted")
+	}
+	if e, _ := s.Get("a"); e == nil {
+		t.Error("entry a should be kept")
+	}
+	s.Set("d", &cache.Entry{Expires: time.Now().Add(-time.Second)})
+	if e, _ := s.Get("d"); e != nil || s.Len() != 1 {
+		t.Errorf("expired entry is returned, len %d", s.Len())
+	}
+}
+
+func TestCacheSafety(t *testing.T) {
+	calls := 0
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.DynamicFilter("/doc/{id}", cache.NewFilter(cache.NewLRUStore(10), &cache.Option{Vary: []string{"Accept"}}))
+	s.DynamicRoute("/doc/{id}", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		calls++
+		q := req.URL.Query()
+		if v := q.Get("cc"); v != "" {
+			rsp.Header().Set("Cache-Control", v)
+		}
+		if v := q.Get("vary"); v != "" {
+			rsp.Header().Set("Vary", v)
+		}
+		rsp.Header().Set("X-Request-Id", strconv.Itoa(calls))
+		rsp.Header().Set("Access-Control-Allow-Origin", "https://a.example.com")
+		rsp.Write([]byte("doc " + req.GetDynamicParam("id")))
+		return 200, nil
+	})
+
+	// responses to authorized requests are not shared unless response allows it
+	ts.GET("/doc/1").WithHeader("Authorization", "Bearer a").Expect(200).Header("X-Cache", "")
+	ts.GET("/doc/1").Expect(200).Header("X-Cache", "MISS")
+	ts.GET("/doc/1").WithHeader("Authorization", "Bearer b").Expect(200).Header("X-Cache", "")
+	ts.GET("/doc/2?cc=public").WithHeader("Authorization", "Bearer a").Expect(200).Header("X-Cache", "MISS")
+	ts.GET("/doc/2?cc=public").WithHeader("Authorization", "Bearer b").Expect(200).Header("X-Cache", "HIT")
+	if calls != 4 {
+		t.Errorf("expect 4 calls, got %d", calls)
+	}
+
+	// vary headers outside the key are not cached
+	ts.GET("/doc/3?vary=Cookie").Expect(200).Header("X-Cache", "")
+	ts.GET("/doc/3?vary=Cookie").Expect(200).Header("X-Cache", "")
+	ts.GET("/doc/3?vary=accept").Expect(200).Header("X-Cache", "MISS")
+	ts.GET("/doc/3?vary=accept").Expect(200).Header("X-Cache", "HIT")
+	if calls != 7 {
+		t.Errorf("expect 7 calls, got %d", calls)
+	}
+
+	// HEAD is served from GET, per request headers are not replayed
+	ts.GET("/doc/4").Expect(200).Header("X-Request-Id", "8")
+	ts.HEAD("/doc/4").Expect(200).Header("X-Cache", "HIT").Body("")
+	ts.GET("/doc/4").Expect(200).Header("X-Cache", "HIT").Body("doc 4").
+		Header("X-Request-Id", "").Header("Access-Control-Allow-Origin", "")
+	if calls != 8 {
+		t.Errorf("expect 8 calls, got %d", calls)
+	}
+}
+
+type mutator struct{}
+
+func (a *mutator) Before(rsp *httpserver.Response, req *httpserver.Request) bool { return true }
+
+func (a *mutator) After(rsp *httpserver.Response, req *httpserver.Request) {
+	if v := rsp.Header()["Etag"]; len(v) > 0 {
+		v[0] = `"mutated"`
+	}
+}
+
+func TestCachedHeaderNotShared(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.Filter("/a", &mutator{})
+	s.Filter("/a", cache.NewFilter(cache.NewLRUStore(10), nil))
+	s.Route("/a", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Header().Set("ETag", `"v1"`)
+		rsp.Write([]byte("a"))
+		return 200, nil
+	})
+	ts.GET("/a").Expect(200).Header("X-Cache", "MISS")
+	ts.GET("/a").Expect(200).Header("X-Cache", "HIT").Header("ETag", `"mutated"`)
+	ts.GET("/a").WithHeader("If-None-Match", `"v1"`).Expect(304)
+}
+
+func TestFileStoreDeleteExpired(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "cache")
+	defer os.RemoveAll(dir)
+	s, err := cache.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("old", &cache.Entry{Expires: time.Now().Add(-time.Second)})
+	s.Set("new", &cache.Entry{Expires: time.Now().Add(time.Minute)})
+	if n, err := s.DeleteExpired(); err != nil || n != 1 {
+		t.Errorf("expect 1 expired entry deleted, got %d, %v", n, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 1 {
+		t.Errorf("expect 1 entry left on disk, got %v", files)
+	}
+	if e, _ := s.Get("new"); e == nil {
+		t.Error("entry new should be kept")
+	}
+}
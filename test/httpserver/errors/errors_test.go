@@ -0,0 +1,31 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+func TestDefaultErrorHandler(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Route("/internal", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		return 502, errors.New("dial tcp 10.0.0.1:8080: connection refused")
+	})
+	s.Route("/public", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		return 400, &httpserver.Problem{Detail: "name is required"}
+	})
+	s.Route("/panic", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		var m map[string]int
+		m["a"] = 1
+		return 200, nil
+	})
+	ts := testserver.New(t, s)
+	ts.GET("/internal").Expect(502).Header("Content-Type", "application/problem+json").
+		JSONBody(`{"type":"about:blank","title":"Bad Gateway","status":502,"instance":"/internal"}`)
+	ts.GET("/public").Expect(400).
+		JSONBody(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"name is required","instance":"/public"}`)
+	ts.GET("/panic").Expect(500).
+		JSONBody(`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/panic"}`)
+}
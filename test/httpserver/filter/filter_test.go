@@ -0,0 +1,42 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+type pass bool
+
+func (a pass) Before(rsp *httpserver.Response, req *httpserver.Request) bool {
+	if !a {
+		rsp.WriteStatusCode(403)
+		rsp.Write([]byte("denied"))
+	}
+	return bool(a)
+}
+
+func (a pass) After(rsp *httpserver.Response, req *httpserver.Request) {
+}
+
+func TestFilterOrder(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.Filter("/a/1", ts.Track("plain", pass(true)))
+	s.DynamicFilter("/{url}/{id}", ts.Track("global", pass(true)))
+	s.DynamicFilterWithPriority("/a/{id}", ts.Track("first", pass(true)), -1)
+	s.Filter("/a/2", ts.Track("deny", pass(false)))
+	s.DynamicFilter("/a/{id}", ts.Track("last", pass(true)))
+	s.DynamicRoute("/a/{id}", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Write([]byte("ok " + req.GetDynamicParam("id")))
+		return 200, nil
+	})
+
+	ts.GET("/a/1").Expect(200).Body("ok 1").Events(
+		"first.Before", "plain.Before", "global.Before", "last.Before",
+		"last.After", "global.After", "plain.After", "first.After")
+	ts.GET("/a/2").Expect(403).Body("denied").Events(
+		"first.Before", "global.Before", "deny.Before",
+		"global.After", "first.After")
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+func text(s string) func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+	return func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Write([]byte(s + req.GetDynamicParam("tenant")))
+		return 200, nil
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.Route("/", text("default"))
+	api := s.Host("api.example.com")
+	api.Route("/", text("api"))
+	api.NotFound(func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.WriteStatusCode(404)
+		rsp.Write([]byte("api not found"))
+		return 404, nil
+	})
+	tenant := s.Host("{tenant}.example.com")
+	tenant.Route("/", text("tenant "))
+	tenant.Filter("/", ts.Track("tenant", &nop{}))
+
+	ts.GET("/").WithHost("api.example.com:8080").Expect(200).Body("api").NotRan("tenant")
+	ts.GET("/x").WithHost("API.example.com").Expect(404).Body("api not found")
+	ts.GET("/").WithHost("shop.example.com").Expect(200).Body("tenant shop").Ran("tenant")
+	ts.GET("/").WithHost("a.b.example.com").Expect(200).Body("default")
+	ts.GET("/").WithHost("other.com").Expect(200).Body("default")
+}
+
+func TestHostRegistration(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "host")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("js"), 0600)
+	up := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("upstream " + r.URL.Path))
+	}))
+	defer up.Close()
+
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	api := s.Host("api.example.com")
+	api.Static("/assets", dir)
+	api.RouteWith("/users", &httpserver.Constraint{Produces: []string{"application/json"}}, text("users"))
+	api.Describe("/users", &httpserver.RouteDoc{Summary: "list users"})
+	api.OpenAPI("/openapi.json", nil)
+	api.WebSocket("/ws", func(conn *httpserver.WSConn, req *httpserver.Request) {})
+	if err := api.Proxy("/legacy", []string{up.URL}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.GET("/assets/app.js").WithHost("api.example.com").Expect(200).Body("js")
+	ts.GET("/users").WithHost("api.example.com").Expect(200).Body("users").Header("Content-Type", "application/json")
+	ts.GET("/users").WithHost("api.example.com").WithHeader("Accept", "text/html").Expect(406)
+	ts.GET("/ws").WithHost("api.example.com").Expect(400)
+	ts.GET("/legacy/a").WithHost("api.example.com").Expect(200).Body("upstream /legacy/a")
+	if b := ts.GET("/openapi.json").WithHost("api.example.com").Expect(200).Recorder.Body.String(); !strings.Contains(b, "list users") {
+		t.Errorf("openapi of host should describe its routes, got %s", b)
+	}
+	for _, p := range []string{"/assets/app.js", "/users", "/ws", "/legacy/a", "/openapi.json"} {
+		ts.GET(p).WithHost("other.com").Expect(404)
+	}
+}
+
+type nop struct{}
+
+func (a *nop) Before(rsp *httpserver.Response, req *httpserver.Request) bool { return true }
+func (a *nop) After(rsp *httpserver.Response, req *httpserver.Request)       {}
+
+func writeCert(t *testing.T, dir, name string) (string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+	return certFile, keyFile
+}
+
+func TestSNI(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sni")
+	defer os.RemoveAll(dir)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	s := httpserver.NewHTTPServerWithOption(&httpserver.ServerOption{Addr: l.Addr().String()})
+	for _, h := range []string{"a.test", "b.test"} {
+		if err := s.Host(h).Certificate(writeCert(t, dir, h)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Finish(func(e error) {})
+	s.ServeHTTPS("", "")
+	defer s.Shutdown()
+
+	for _, h := range []string{"a.test", "b.test"} {
+		var conn *tls.Conn
+		for i := 0; i < 50; i++ {
+			if conn, err = tls.Dial("tcp", l.Addr().String(), &tls.Config{ServerName: h, InsecureSkipVerify: true}); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cn := conn.ConnectionState().PeerCertificates[0].Subject.CommonName; cn != h {
+			t.Errorf("expect certificate of %s, got %s", h, cn)
+		}
+		conn.Close()
+	}
+	if _, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{ServerName: "c.test", InsecureSkipVerify: true}); err == nil {
+		t.Error("handshake of unknown host should fail")
+	}
+}
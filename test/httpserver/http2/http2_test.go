@@ -0,0 +1,104 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+func TestH2C(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(&httpserver.ServerOption{HTTP2: &httpserver.HTTP2Option{H2C: true, MaxConcurrentStreams: 10}})
+	s.Route("/", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		if req.ProtoMajor == 1 && rsp.Push("/app.js", nil) != http.ErrNotSupported {
+			t.Error("push should not be supported by http/1.1")
+		}
+		rsp.Write([]byte(req.Proto))
+		rsp.SetTrailer("Checksum", "abc")
+		return 200, nil
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	u := "http://" + l.Addr().String() + "/"
+
+	p := new(http.Protocols)
+	p.SetUnencryptedHTTP2(true)
+	clients := map[string]*http.Client{
+		"HTTP/2.0": {Transport: &http.Transport{Protocols: p}},
+		"HTTP/1.1": {Transport: &http.Transport{}},
+	}
+	for proto, c := range clients {
+		rsp, err := c.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if string(b) != proto || rsp.Proto != proto {
+			t.Errorf("expect %s, got %s %s", proto, rsp.Proto, b)
+		}
+		if rsp.Trailer.Get("Checksum") != "abc" {
+			t.Errorf("%s: trailer is missing: %v", proto, rsp.Trailer)
+		}
+	}
+}
+
+func TestHTTP2Only(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "h2")
+	defer os.RemoveAll(dir)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "localhost"}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	kb, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile := filepath.Join(dir, "c.crt"), filepath.Join(dir, "c.key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	s := httpserver.NewHTTPServerWithOption(&httpserver.ServerOption{Addr: l.Addr().String(), HTTP2: &httpserver.HTTP2Option{DisableHTTP1: true}})
+	s.Finish(func(e error) {})
+	s.ServeHTTPS(certFile, keyFile)
+	defer s.Shutdown()
+
+	var conn *tls.Conn
+	for i := 0; i < 50; i++ {
+		if conn, err = tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}}); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := conn.ConnectionState().NegotiatedProtocol; p != "h2" {
+		t.Errorf("expect h2, got %q", p)
+	}
+	conn.Close()
+	if conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"http/1.1"}}); err == nil {
+		if p := conn.ConnectionState().NegotiatedProtocol; p == "http/1.1" {
+			t.Error("http/1.1 should not be advertised")
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+	"github.com/FrankLeeC/Aurora/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.MetricsWithRegistry("/metrics", metrics.NewRegistry())
+	s.DynamicRoute("/users/{id}", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Write([]byte("ok"))
+		return 200, nil
+	})
+	ts := testserver.New(t, s)
+	ts.GET("/users/1").Expect(200)
+	ts.GET("/users/2").Expect(200)
+	ts.NewRequest("FOO", "/users/1").Expect(200)
+	ts.NewRequest("BAR", "/users/1").Expect(200)
+	ts.GET("/none").Expect(404)
+
+	body := ts.GET("/metrics").Expect(200).Recorder.Body.String()
+	for _, s := range []string{
+		`http_requests_total{route="/users/{id}",method="GET",code="200"} 2`,
+		`http_requests_total{route="/users/{id}",method="OTHER",code="200"} 2`,
+		`http_requests_total{route="NOT_FOUND",method="GET",code="404"} 1`,
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("%s is missing in\n%s", s, body)
+		}
+	}
+	if strings.Contains(body, "FOO") || strings.Contains(body, "BAR") {
+		t.Errorf("unknown methods should be labeled OTHER:\n%s", body)
+	}
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/middleware"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+	"github.com/FrankLeeC/Aurora/log"
+)
+
+var body = strings.Repeat("hello world ", 100)
+
+func hello(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+	rsp.Header().Set("Content-Type", "text/plain")
+	rsp.Write([]byte(body))
+	return 200, nil
+}
+
+func TestCompress(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Route("/a", hello)
+	s.Route("/sent", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.WriteHeader(200)
+		rsp.Write([]byte(body))
+		return 200, nil
+	})
+	s.Filter("/a", middleware.NewCompress(-1, 100))
+	s.Filter("/sent", middleware.NewCompress(-1, 100))
+	ts := testserver.New(t, s)
+
+	rsp := ts.GET("/a").WithHeader("Accept-Encoding", "gzip, deflate").Expect(200).
+		Header("Content-Encoding", "gzip").Header("Vary", "Accept-Encoding").Then()
+	r, err := gzip.NewReader(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(r); string(b) != body {
+		t.Errorf("unexpected body %q", b)
+	}
+	rsp = ts.GET("/a").WithHeader("Accept-Encoding", "deflate").Expect(200).Header("Content-Encoding", "deflate").Then()
+	zr, err := zlib.NewReader(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != body {
+		t.Errorf("unexpected body %q", b)
+	}
+	ts.GET("/a").WithHeader("Accept-Encoding", "gzip;q=0").Expect(200).Header("Content-Encoding", "").Body(body)
+	ts.GET("/sent").WithHeader("Accept-Encoding", "gzip").Expect(200).Header("Content-Encoding", "").Body(body)
+}
+
+func TestCORS(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Route("/a", hello)
+	s.Filter("/a", middleware.NewCORS(&middleware.CORSOption{AllowOrigins: []string{"https://a.com"}, MaxAge: 60}))
+	s.Filter("/a", middleware.NewCompress(-1, 0))
+	ts := testserver.New(t, s)
+
+	ts.NewRequest("OPTIONS", "/a").WithHeader("Origin", "https://a.com").
+		WithHeader("Access-Control-Request-Method", "PUT").WithHeader("Access-Control-Request-Headers", "X-Token").
+		WithHeader("Accept-Encoding", "gzip").Expect(204).
+		Header("Access-Control-Allow-Origin", "https://a.com").Header("Access-Control-Allow-Headers", "X-Token").
+		Header("Access-Control-Max-Age", "60").Body("")
+	ts.NewRequest("OPTIONS", "/a").WithHeader("Origin", "https://b.com").
+		WithHeader("Access-Control-Request-Method", "PUT").Expect(403)
+	ts.GET("/a").WithHeader("Origin", "https://b.com").Expect(200).Header("Access-Control-Allow-Origin", "")
+	ts.GET("/a").WithHeader("Origin", "https://a.com").Expect(200).Header("Access-Control-Allow-Origin", "https://a.com")
+}
+
+func TestRateLimit(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Route("/a", hello)
+	s.Filter("/a", middleware.NewRateLimit(0.001, 2, middleware.KeyByHeader("X-Key")))
+	ts := testserver.New(t, s)
+	ts.GET("/a").WithHeader("X-Key", "1").Expect(200)
+	ts.GET("/a").WithHeader("X-Key", "1").Expect(200)
+	rsp := ts.GET("/a").WithHeader("X-Key", "1").Expect(429).Then()
+	if rsp.Header.Get("Retry-After") == "" {
+		t.Error("Retry-After is missing")
+	}
+	ts.GET("/a").WithHeader("X-Key", "2").Expect(200)
+}
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := log.New(log.NewWriterSink(buf, &log.SinkOption{Encoder: &log.JSONEncoder{}}), nil)
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Route("/a", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Write([]byte(middleware.RequestID(req)))
+		return 200, nil
+	})
+	s.Filter("/a", middleware.NewRequestID())
+	s.Filter("/a", middleware.NewAccessLog(logger, middleware.JSONLog))
+	ts := testserver.New(t, s)
+
+	ts.GET("/a").WithHeader(middleware.RequestIDHeader, "abc").Expect(200).Header(middleware.RequestIDHeader, "abc").Body("abc")
+	rsp := ts.GET("/a").Expect(200).Then()
+	id := rsp.Header.Get(middleware.RequestIDHeader)
+	if len(id) != 32 {
+		t.Errorf("unexpected generated id %q", id)
+	}
+	logger.Close()
+
+	ls := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(ls) != 2 {
+		t.Fatalf("expect 2 access logs, got %q", buf.String())
+	}
+	var line struct{ Msg string }
+	if err := json.Unmarshal([]byte(ls[1]), &line); err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(line.Msg), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["method"] != "GET" || m["uri"] != "/a" || m["status"] != 200.0 || m["request_id"] != id || m["size"] != 32.0 {
+		t.Errorf("unexpected access log %v", m)
+	}
+}
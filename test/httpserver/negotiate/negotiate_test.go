@@ -0,0 +1,133 @@
+package test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+	"github.com/FrankLeeC/Aurora/log"
+)
+
+func reply(s string) func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+	return func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Write([]byte(s))
+		return 200, nil
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Versioning(&httpserver.VersionOption{Default: "1"})
+	s.RouteWith("/users", &httpserver.Constraint{Produces: []string{"application/json"}, Version: "1"}, reply("json1"))
+	s.RouteWith("/users", &httpserver.Constraint{Produces: []string{"application/x-protobuf"}, Version: "1"}, reply("pb1"))
+	s.RouteWith("/users", &httpserver.Constraint{Produces: []string{"application/json"}, Version: "2"}, reply("json2"))
+	s.DynamicRouteWith("/users/{id}", &httpserver.Constraint{Consumes: []string{"application/json"}}, reply("update"))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	u := "http://" + l.Addr().String()
+
+	cases := []struct {
+		method, path, accept, contentType, version string
+		code                                       int
+		body                                       string
+	}{
+		{"GET", "/users", "", "", "", 200, "json1"},
+		{"GET", "/users", "application/x-protobuf, application/json;q=0.5", "", "", 200, "pb1"},
+		{"GET", "/users", "application/*", "", "2", 200, "json2"},
+		{"GET", "/users", "text/html", "", "", 406, ""},
+		{"GET", "/users", "application/json;q=0, */*", "", "", 200, "pb1"},
+		{"GET", "/users", "application/json;q=0, application/*", "", "2", 406, ""},
+		{"GET", "/users", "application/*;q=0, */*", "", "", 406, ""},
+		{"GET", "/users", "", "", "3", 404, ""},
+		{"PUT", "/users/1", "", "application/json; charset=utf-8", "", 200, "update"},
+		{"PUT", "/users/1", "", "application/xml", "", 415, ""},
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest(c.method, u+c.path, strings.NewReader("{}"))
+		r.Header.Set("Accept", c.accept)
+		r.Header.Set("Content-Type", c.contentType)
+		if c.version != "" {
+			r.Header.Set("API-Version", c.version)
+		}
+		rsp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if rsp.StatusCode != c.code || (c.body != "" && string(b) != c.body) {
+			t.Errorf("%s %s accept %q version %q: %d %s", c.method, c.path, c.accept, c.version, rsp.StatusCode, b)
+		}
+	}
+}
+
+func TestPathPrefixVersion(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Versioning(&httpserver.VersionOption{PathPrefix: true})
+	s.RouteWith("/users", &httpserver.Constraint{Version: "v1"}, reply("v1"))
+	s.RouteWith("/users", &httpserver.Constraint{Version: "v2"}, func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Write([]byte(req.Version()))
+		return 200, nil
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	for _, v := range []string{"v1", "v2"} {
+		rsp, err := http.Get("http://" + l.Addr().String() + "/" + v + "/users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if string(b) != v {
+			t.Errorf("expect %s, got %s", v, b)
+		}
+	}
+}
+
+type entries struct {
+	mutex *sync.Mutex
+	msgs  []string
+}
+
+func (a *entries) Write(e *log.Entry) error {
+	a.mutex.Lock()
+	a.msgs = append(a.msgs, e.Message)
+	a.mutex.Unlock()
+	return nil
+}
+
+func (a *entries) Close() error { return nil }
+
+func TestNegotiationMissNotLogged(t *testing.T) {
+	sink := &entries{mutex: new(sync.Mutex)}
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Logger(log.New(sink, nil))
+	s.RouteWith("/users", &httpserver.Constraint{Produces: []string{"application/json"}, Consumes: []string{"application/json"}}, reply("json"))
+	s.Route("/fail", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		return 500, errors.New("boom")
+	})
+	ts := testserver.New(t, s)
+	ts.GET("/users").WithHeader("Accept", "text/html").Expect(406)
+	ts.PUT("/users").WithHeader("Content-Type", "text/xml").WithBody([]byte("<a/>")).Expect(415)
+	if len(sink.msgs) != 0 {
+		t.Errorf("client negotiation failures should not be logged: %v", sink.msgs)
+	}
+	ts.GET("/fail").Expect(500)
+	if len(sink.msgs) != 1 || !strings.Contains(sink.msgs[0], "boom") {
+		t.Errorf("handler error should be logged: %v", sink.msgs)
+	}
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+type Base struct {
+	ID      int64     `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+type User struct {
+	Base
+	Name    string   `json:"name"`
+	Email   *string  `json:"email"`
+	Tags    []string `json:"tags,omitempty"`
+	Friends []*User  `json:"friends,omitempty"`
+	secret  string
+}
+
+func ok(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+	return 200, nil
+}
+
+func TestOpenAPI(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.DynamicRoute("/users/{id}", ok)
+	s.Describe("/users/{id}", &httpserver.RouteDoc{Summary: "get user", Response: User{}})
+	s.Describe("/users/{id}", &httpserver.RouteDoc{Method: "PUT", Request: &User{}, Responses: map[int]interface{}{404: nil}})
+	s.Route("/health", ok)
+	s.OpenAPI("/openapi.json", &httpserver.OpenAPIInfo{Title: "test", Version: "1"})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	rsp, err := http.Get("http://" + l.Addr().String() + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Summary    string
+			Parameters []struct{ Name, In string }
+			Responses  map[string]interface{}
+		}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{}
+				Required   []string
+			}
+		}
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, c := doc.Paths["/openapi.json"]; c {
+		t.Error("openapi document should not document itself")
+	}
+	if _, c := doc.Paths["/health"]["get"]; !c {
+		t.Error("route without metadata should be documented as get")
+	}
+	get := doc.Paths["/users/{id}"]["get"]
+	if get.Summary != "get user" || len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" {
+		t.Errorf("unexpected get operation: %+v", get)
+	}
+	if _, c := doc.Paths["/users/{id}"]["put"].Responses["404"]; !c {
+		t.Error("404 response of put is missing")
+	}
+	user := doc.Components.Schemas["User"]
+	for _, p := range []string{"id", "created", "name", "email", "tags", "friends"} {
+		if _, c := user.Properties[p]; !c {
+			t.Errorf("property %s is missing", p)
+		}
+	}
+	if _, c := user.Properties["secret"]; c || len(user.Properties) != 6 {
+		t.Errorf("unexpected properties: %v", user.Properties)
+	}
+	if len(user.Required) != 3 {
+		t.Errorf("expect id, created and name required, got %v", user.Required)
+	}
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+func echo(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		rsp.WriteStatusCode(http.StatusRequestEntityTooLarge)
+		return http.StatusRequestEntityTooLarge, nil
+	}
+	rsp.Write(b)
+	return 200, nil
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(&httpserver.ServerOption{MaxBodyBytes: 10})
+	s.Route("/echo", echo)
+	ts := testserver.New(t, s)
+	ts.POST("/echo").WithBody([]byte("0123456789")).Expect(200).Body("0123456789")
+	ts.POST("/echo").WithBody([]byte("0123456789a")).Expect(413).Body("")
+
+	// body without content length is cut by MaxBodyBytes
+	req := httptest.NewRequest("POST", "/echo", ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 20))))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != 413 {
+		t.Errorf("expect 413 for body without content length, got %d", rec.Code)
+	}
+}
+
+func listen(t *testing.T, s *httpserver.HTTPServer) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	return l.Addr().String()
+}
+
+func TestHeaderLimits(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(&httpserver.ServerOption{MaxHeaderBytes: 1024, ReadHeaderTimeout: 100 * time.Millisecond})
+	s.Route("/echo", echo)
+	addr := listen(t, s)
+	defer s.Shutdown()
+
+	req, _ := http.NewRequest("GET", "http://"+addr+"/echo", nil)
+	req.Header.Set("X-Big", strings.Repeat("a", 8192))
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expect 431, got %d", rsp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /echo HTTP/1.1\r\nHost: a\r\n")) // header is never finished
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	begin := time.Now()
+	if _, err := bufio.NewReader(conn).ReadString('\n'); err == nil || time.Since(begin) > time.Second {
+		t.Errorf("connection should be closed after ReadHeaderTimeout, err: %v, after %v", err, time.Since(begin))
+	}
+}
+
+func writeCert(t *testing.T, certFile, keyFile, name string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, _ := x509.MarshalECPrivateKey(key)
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+}
+
+func commonName(t *testing.T, addr string, c *tls.Config) (string, error) {
+	var conn *tls.Conn
+	var err error
+	for i := 0; i < 50; i++ {
+		if conn, err = tls.Dial("tcp", addr, c); err == nil {
+			break
+		}
+		if _, ok := err.(*net.OpError); !ok {
+			return "", err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestTLSReload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tls")
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "a.crt"), filepath.Join(dir, "a.key")
+	writeCert(t, certFile, keyFile, "v1")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	addr := l.Addr().String()
+	s := httpserver.NewHTTPServerWithOption(&httpserver.ServerOption{
+		Addr: addr,
+		TLS:  &httpserver.TLSOption{CertFile: certFile, KeyFile: keyFile, ReloadInterval: 20 * time.Millisecond},
+	})
+	s.Finish(func(e error) {})
+	s.ServeHTTPS("", "")
+	defer s.Shutdown()
+
+	if cn, err := commonName(t, addr, &tls.Config{InsecureSkipVerify: true}); err != nil || cn != "v1" {
+		t.Fatalf("expect v1, got %s %v", cn, err)
+	}
+	if _, err := commonName(t, addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS11}); err == nil {
+		t.Error("tls 1.1 should be refused by default")
+	}
+
+	writeCert(t, certFile, keyFile, "v2")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		cn, err := commonName(t, addr, &tls.Config{InsecureSkipVerify: true})
+		if err == nil && cn == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate is not reloaded, got %s %v", cn, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
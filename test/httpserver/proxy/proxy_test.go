@@ -0,0 +1,176 @@
+package test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+func serve(t *testing.T, s *httpserver.HTTPServer) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	return "http://" + l.Addr().String()
+}
+
+func get(t *testing.T, u string) (int, string) {
+	rsp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	b, _ := ioutil.ReadAll(rsp.Body)
+	return rsp.StatusCode, string(b)
+}
+
+func TestProxyRoundRobinAndStripPrefix(t *testing.T) {
+	var hits [2]int32
+	backends := make([]string, 2)
+	for i := range backends {
+		i := i
+		b := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits[i], 1)
+			rw.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+		}))
+		defer b.Close()
+		backends[i] = b.URL
+	}
+	s := httpserver.NewHTTPServerWithOption(nil)
+	if err := s.Proxy("/legacy", backends, &httpserver.ProxyOption{StripPrefix: "/legacy"}); err != nil {
+		t.Fatal(err)
+	}
+	u := serve(t, s)
+	defer s.Shutdown()
+
+	for i := 0; i < 4; i++ {
+		code, body := get(t, u+"/legacy/a/b?x=1")
+		if code != 200 || body != "/a/b?x=1" {
+			t.Fatalf("unexpected response %d %q", code, body)
+		}
+	}
+	if hits[0] != 2 || hits[1] != 2 {
+		t.Errorf("requests are not balanced: %v", hits)
+	}
+}
+
+func TestProxyRetryAndRewrite(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Upstream", "up")
+		rw.Write([]byte(r.URL.Path + " " + r.Header.Get("X-Token")))
+	}))
+	defer up.Close()
+
+	s := httpserver.NewHTTPServerWithOption(nil)
+	err := s.Proxy("/users/{id}", []string{down.URL, up.URL}, &httpserver.ProxyOption{
+		Retries:        1,
+		Rewrite:        "/api/user/{id}",
+		RequestHeaders: map[string]string{"X-Token": "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := serve(t, s)
+	defer s.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		code, body := get(t, u+"/users/42")
+		if code != 200 || body != "/api/user/42 secret" {
+			t.Fatalf("unexpected response %d %q", code, body)
+		}
+	}
+}
+
+func TestProxyHopHeadersAndErrors(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Connection", "X-Upstream-Hop")
+		rw.Header().Set("X-Upstream-Hop", "1")
+		rw.Write([]byte(r.Header.Get("X-Hop") + "|" + r.Header.Get("X-Keep") + "|" + r.Header.Get("Keep-Alive")))
+	}))
+	defer up.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := "http://" + l.Addr().String()
+	l.Close()
+
+	s := httpserver.NewHTTPServerWithOption(nil)
+	if err := s.Proxy("/up", []string{up.URL}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Proxy("/dead", []string{dead}, nil); err != nil {
+		t.Fatal(err)
+	}
+	u := serve(t, s)
+	defer s.Shutdown()
+
+	req, _ := http.NewRequest("GET", u+"/up", nil)
+	req.Header.Set("Connection", "X-Hop, Keep-Alive")
+	req.Header.Set("X-Hop", "1")
+	req.Header.Set("X-Keep", "1")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if string(b) != "|1|" {
+		t.Errorf("hop-by-hop headers are forwarded: %q", b)
+	}
+	if rsp.Header.Get("X-Upstream-Hop") != "" {
+		t.Error("hop-by-hop header of upstream is forwarded")
+	}
+
+	code, body := get(t, u+"/dead")
+	if code != http.StatusBadGateway || body != "" {
+		t.Errorf("expect bare 502, got %d %q", code, body)
+	}
+}
+
+func TestProxyBodyLimits(t *testing.T) {
+	var got int32
+	b := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		atomic.StoreInt32(&got, int32(n))
+		rw.Write(bytes.Repeat([]byte("x"), 100))
+	}))
+	defer b.Close()
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Proxy("/small", []string{b.URL}, &httpserver.ProxyOption{MaxBodySize: 10})
+	s.Proxy("/tiny", []string{b.URL}, &httpserver.ProxyOption{MaxResponseSize: 50})
+	u := serve(t, s)
+
+	post := func(path string, n int) int {
+		rsp, err := http.Post(u+path, "text/plain", bytes.NewReader(make([]byte, n)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+	if code := post("/small", 10); code != 200 || atomic.LoadInt32(&got) != 10 {
+		t.Errorf("body within limit: expect 200, got %d, upstream read %d", code, got)
+	}
+	atomic.StoreInt32(&got, -1)
+	if code := post("/small", 11); code != 413 || atomic.LoadInt32(&got) != -1 {
+		t.Errorf("body over limit: expect 413 without forwarding, got %d", code)
+	}
+	if code, body := get(t, u+"/tiny"); code != 502 || body != "" {
+		t.Errorf("response over limit: expect bare 502, got %d %q", code, body)
+	}
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+type panicFilter struct {
+	before bool
+	after  bool
+}
+
+func (a *panicFilter) Before(rsp *httpserver.Response, req *httpserver.Request) bool {
+	if a.before {
+		panic("before")
+	}
+	return true
+}
+
+func (a *panicFilter) After(rsp *httpserver.Response, req *httpserver.Request) {
+	if a.after {
+		panic("after")
+	}
+}
+
+func ok(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+	rsp.Write([]byte("ok"))
+	return 200, nil
+}
+
+func TestRecoverFilters(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.Route("/before", ok)
+	s.Route("/after", ok)
+	s.Route("/handler", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		panic("handler")
+	})
+	s.Filter("/before", ts.Track("outer", &panicFilter{}))
+	s.Filter("/before", ts.Track("panic", &panicFilter{before: true}))
+	s.Filter("/after", ts.Track("panic", &panicFilter{after: true}))
+	s.Filter("/handler", ts.Track("outer", &panicFilter{}))
+
+	ts.GET("/before").Expect(500).Events("outer.Before", "panic.Before", "outer.After")
+	ts.GET("/after").Expect(500).Events("panic.Before", "panic.After")
+	ts.GET("/handler").Expect(500).Events("outer.Before", "outer.After")
+	ts.GET("/handler").Expect(500) // server keeps serving
+}
+
+func TestAbortHandler(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Route("/abort", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		panic(http.ErrAbortHandler)
+	})
+	h := s.Handler()
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expect http.ErrAbortHandler, got %v", v)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	t.Error("http.ErrAbortHandler should not be recovered")
+}
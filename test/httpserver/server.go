@@ -3,9 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"time"
 
 	"github.com/FrankLeeC/Aurora/httpserver"
 )
@@ -14,16 +12,16 @@ func main() {
 	s := httpserver.NewHTTPServer(9090)
 	s.Route("/test", test)
 	s.Route("/ok", ok)
+	s.Readiness("/readyz")
 	s.DynamicFilter("/{url}", &testFilter{})
+	s.OnShutdown(func() {
+		fmt.Println("close resources")
+	})
 	s.Finish(func(e error) {
 		fmt.Printf("close server error: %v\n", e)
 	})
 	s.ServeHTTP()
-	c := make(chan os.Signal)
-	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
-	sig := <-c
-	fmt.Printf("receive sig: %v\n", sig)
-	s.Shutdown()
+	s.HandleSignals(time.Second, 10*time.Second)
 }
 
 func test(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
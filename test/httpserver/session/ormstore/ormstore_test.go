@@ -0,0 +1,127 @@
+package test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver/session/ormstore"
+	"github.com/FrankLeeC/Aurora/orm"
+)
+
+// fakeDriver understands the statements of ormstore only
+type fakeDriver struct {
+	mutex *sync.Mutex
+	rows  map[string]fakeRow
+}
+
+type fakeRow struct {
+	data     string
+	expireAt int64
+}
+
+func (a *fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{d: a}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (a *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: a.d, query: query}, nil
+}
+func (a *fakeConn) Close() error              { return nil }
+func (a *fakeConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (a *fakeStmt) Close() error  { return nil }
+func (a *fakeStmt) NumInput() int { return -1 }
+
+func (a *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	a.d.mutex.Lock()
+	defer a.d.mutex.Unlock()
+	n := int64(0)
+	switch {
+	case strings.HasPrefix(a.query, "INSERT"):
+		a.d.rows[args[0].(string)] = fakeRow{data: args[1].(string), expireAt: args[2].(int64)}
+		n = 1
+	case strings.Contains(a.query, "WHERE ID = ?"):
+		if _, c := a.d.rows[args[0].(string)]; c {
+			delete(a.d.rows, args[0].(string))
+			n = 1
+		}
+	case strings.Contains(a.query, "WHERE EXPIRE_AT <= ?"):
+		for id, r := range a.d.rows {
+			if r.expireAt <= args[0].(int64) {
+				delete(a.d.rows, id)
+				n++
+			}
+		}
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (a *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	a.d.mutex.Lock()
+	defer a.d.mutex.Unlock()
+	rows := &fakeRows{}
+	if r, c := a.d.rows[args[0].(string)]; c && r.expireAt > args[1].(int64) {
+		rows.data = []string{r.data}
+	}
+	return rows, nil
+}
+
+type fakeRows struct{ data []string }
+
+func (a *fakeRows) Columns() []string { return []string{"DATA"} }
+func (a *fakeRows) Close() error      { return nil }
+
+func (a *fakeRows) Next(dest []driver.Value) error {
+	if len(a.data) == 0 {
+		return io.EOF
+	}
+	dest[0], a.data = a.data[0], a.data[1:]
+	return nil
+}
+
+func TestStore(t *testing.T) {
+	defer os.RemoveAll("./AURORA_ORM_LOG")
+	d := &fakeDriver{mutex: new(sync.Mutex), rows: make(map[string]fakeRow)}
+	sql.Register("mysql", d)
+	if err := orm.RegisterDataSource("session", "fake", nil); err != nil {
+		t.Fatal(err)
+	}
+	defer orm.Close()
+	s, err := ormstore.NewStore("session", "T_SESSION")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ormstore.NewStore("unknown", "T_SESSION"); err == nil {
+		t.Error("unknown data source should be refused")
+	}
+
+	s.Save("a", map[string]interface{}{"user": "frank", "age": 30}, time.Hour)
+	s.Save("b", map[string]interface{}{"user": "lee"}, -time.Second)
+	if v, err := s.Load("a"); err != nil || v["user"] != "frank" || v["age"] != 30.0 {
+		t.Errorf("unexpected session a: %v, %v", v, err)
+	}
+	if v, err := s.Load("b"); err != nil || v != nil {
+		t.Errorf("expired session b is loaded: %v, %v", v, err)
+	}
+	if n, err := s.DeleteExpired(); err != nil || n != 1 {
+		t.Errorf("expect 1 expired session deleted, got %d, %v", n, err)
+	}
+	s.Delete("a")
+	if v, _ := s.Load("a"); v != nil {
+		t.Errorf("deleted session a is loaded: %v", v)
+	}
+	if len(d.rows) != 0 {
+		t.Errorf("table should be empty, got %v", d.rows)
+	}
+}
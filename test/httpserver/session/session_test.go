@@ -0,0 +1,148 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/session"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+func TestCodec(t *testing.T) {
+	for _, key := range []string{"", "0123456789abcdef"} {
+		c, err := session.NewCodec([]byte("sign key"), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := c.Encode("sid", "value")
+		if v, err := c.Decode("sid", s, time.Minute); err != nil || v != "value" {
+			t.Errorf("decode %q: %q, %v", s, v, err)
+		}
+		if _, err := c.Decode("other", s, 0); err != session.ErrInvalidCookie {
+			t.Errorf("cookie of another name should be refused, got %v", err)
+		}
+		if _, err := c.Decode("sid", "x"+s, 0); err != session.ErrInvalidCookie {
+			t.Errorf("tampered cookie should be refused, got %v", err)
+		}
+	}
+	if _, err := session.NewCodec([]byte("sign key"), "short"); err == nil {
+		t.Error("invalid aes key should be refused")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := session.NewMemoryStore()
+	s.Save("a", map[string]interface{}{"user": "frank"}, time.Hour)
+	s.Save("b", map[string]interface{}{"user": "lee"}, -time.Second)
+	if v, _ := s.Load("a"); v == nil || v["user"] != "frank" {
+		t.Errorf("unexpected session a: %v", v)
+	}
+	if v, _ := s.Load("b"); v != nil {
+		t.Errorf("expired session b is loaded: %v", v)
+	}
+	s.Delete("a")
+	if v, _ := s.Load("a"); v != nil {
+		t.Errorf("deleted session a is loaded: %v", v)
+	}
+}
+
+func sessionCookie(t *testing.T, r *testserver.Result) *http.Cookie {
+	t.Helper()
+	for _, c := range r.Then().Cookies() {
+		if c.Name == "AURORA_SESSION" {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestFilter(t *testing.T) {
+	codec, _ := session.NewCodec([]byte("sign key"), "")
+	store := session.NewMemoryStore()
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.DynamicFilter("/{action}", session.NewFilter(store, codec, nil))
+	s.DynamicRoute("/{action}", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		sess := session.Get(req)
+		switch req.GetDynamicParam("action") {
+		case "login":
+			sess.Set("user", "frank")
+		case "rotate":
+			sess.Rotate()
+		case "logout":
+			sess.Destroy()
+		}
+		user, _ := sess.Get("user").(string)
+		rsp.Write([]byte(sess.ID() + " " + user))
+		return 200, nil
+	})
+	send := func(action string, c *http.Cookie) (*testserver.Result, string) {
+		r := ts.GET("/" + action)
+		if c != nil {
+			r.WithHeader("Cookie", c.Name+"="+c.Value)
+		}
+		res := r.Expect(200)
+		return res, res.Recorder.Body.String()
+	}
+
+	if res, _ := send("me", nil); sessionCookie(t, res) != nil {
+		t.Error("untouched new session should not set cookie")
+	}
+	res, body := send("login", nil)
+	c1 := sessionCookie(t, res)
+	if c1 == nil || c1.HttpOnly != true {
+		t.Fatalf("login should set http only cookie, got %v", c1)
+	}
+	id1 := body[:len(body)-len(" frank")]
+	if v, _ := store.Load(id1); v["user"] != "frank" {
+		t.Errorf("session is not saved: %v", v)
+	}
+	if res, body := send("me", c1); body != id1+" frank" || sessionCookie(t, res) != nil {
+		t.Errorf("session should be loaded without new cookie, got %q", body)
+	}
+
+	res, body = send("rotate", c1)
+	c2 := sessionCookie(t, res)
+	if c2 == nil || body == id1+" frank" || body[len(body)-len(" frank"):] != " frank" {
+		t.Fatalf("rotate should keep values under a new id and cookie, got %q %v", body, c2)
+	}
+	id2 := body[:len(body)-len(" frank")]
+	if v, _ := store.Load(id1); v != nil {
+		t.Errorf("old id should be deleted after rotate: %v", v)
+	}
+	if v, _ := store.Load(id2); v["user"] != "frank" {
+		t.Errorf("rotated session is not saved: %v", v)
+	}
+	if _, body := send("me", c1); body[len(body)-1] != ' ' {
+		t.Errorf("old cookie should not load session, got %q", body)
+	}
+
+	res, _ = send("logout", c2)
+	if c := sessionCookie(t, res); c == nil || c.MaxAge >= 0 {
+		t.Errorf("logout should expire cookie, got %v", c)
+	}
+	if v, _ := store.Load(id2); v != nil {
+		t.Errorf("destroyed session is still stored: %v", v)
+	}
+}
+
+type brokenStore struct{ session.Store }
+
+func (a *brokenStore) Load(id string) (map[string]interface{}, error) {
+	return nil, errors.New("store is down")
+}
+
+func TestFilterStoreError(t *testing.T) {
+	codec, _ := session.NewCodec([]byte("sign key"), "")
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.Filter("/me", ts.Track("session", session.NewFilter(&brokenStore{session.NewMemoryStore()}, codec, nil)))
+	s.Route("/me", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		t.Error("handler should not run if session can not be loaded")
+		return 200, nil
+	})
+	ts.GET("/me").WithHeader("Cookie", "AURORA_SESSION="+codec.Encode("AURORA_SESSION", "x")).Expect(500).Events("session.Before")
+}
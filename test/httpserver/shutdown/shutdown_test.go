@@ -0,0 +1,121 @@
+package test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+// serve start s on a random port, returns base url
+func serve(t *testing.T, s *httpserver.HTTPServer) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ServeListener(l)
+	return "http://" + l.Addr().String()
+}
+
+func get(url string) (int, string, error) {
+	rsp, err := http.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer rsp.Body.Close()
+	b, err := ioutil.ReadAll(rsp.Body)
+	return rsp.StatusCode, string(b), err
+}
+
+func TestShutdownDrains(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	started := make(chan struct{})
+	s.Route("/slow", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		rsp.Write([]byte("done"))
+		return 200, nil
+	})
+	s.Readiness("/readyz")
+	order := make([]string, 0)
+	mutex := new(sync.Mutex)
+	add := func(s string) {
+		mutex.Lock()
+		order = append(order, s)
+		mutex.Unlock()
+	}
+	s.OnShutdown(func() { add("hook1") })
+	s.OnShutdown(func() { add("hook2") })
+	finished := make(chan error, 1)
+	s.Finish(func(err error) {
+		add("finish")
+		finished <- err
+	})
+	base := serve(t, s)
+
+	if code, _, err := get(base + "/readyz"); err != nil || code != 200 {
+		t.Fatalf("readyz before shutdown: %d %v", code, err)
+	}
+	type result struct {
+		code int
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		code, body, err := get(base + "/slow")
+		slow <- result{code, body, err}
+	}()
+	<-started
+	if err := s.ShutdownWithTimeout(5 * time.Second); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	r := <-slow
+	if r.err != nil || r.code != 200 || r.body != "done" {
+		t.Errorf("in-flight request is not drained: %+v", r)
+	}
+	if err := <-finished; err != nil {
+		t.Errorf("finish got %v", err)
+	}
+	if strings.Join(order, ",") != "hook1,hook2,finish" {
+		t.Errorf("unexpected order %v", order)
+	}
+	if s.Ready() {
+		t.Error("server should not be ready after shutdown")
+	}
+	if _, _, err := get(base + "/readyz"); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+	s.Shutdown() // shutdown twice is harmless
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	started := make(chan struct{})
+	s.Route("/stuck", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		close(started)
+		<-req.Context().Done() // closed when connection is closed forcibly
+		return 200, nil
+	})
+	finished := make(chan error, 1)
+	s.Finish(func(err error) { finished <- err })
+	base := serve(t, s)
+	go get(base + "/stuck")
+	<-started
+	begin := time.Now()
+	if err := s.ShutdownWithTimeout(100 * time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("expect context.DeadlineExceeded, got %v", err)
+	}
+	if d := time.Since(begin); d > 2*time.Second {
+		t.Errorf("shutdown took %v", d)
+	}
+	if err := <-finished; err != context.DeadlineExceeded {
+		t.Errorf("finish got %v", err)
+	}
+}
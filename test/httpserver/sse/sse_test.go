@@ -0,0 +1,85 @@
+package test
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+func serve(t *testing.T, s *httpserver.HTTPServer) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	return "http://" + l.Addr().String()
+}
+
+func TestSSE(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(&httpserver.ServerOption{WriteTimeout: 100 * time.Millisecond})
+	done := make(chan struct{})
+	s.SSE("/events", func(stream *httpserver.EventStream, req *httpserver.Request) {
+		defer close(done)
+		stream.SetKeepAlive(50 * time.Millisecond)
+		start, _ := strconv.Atoi(stream.LastEventID())
+		for i := start + 1; i <= start+3; i++ {
+			time.Sleep(100 * time.Millisecond) // stream outlives WriteTimeout
+			if err := stream.Send(&httpserver.Event{ID: strconv.Itoa(i), Event: "tick", Data: "line1\nline2"}); err != nil {
+				t.Errorf("send %d: %v", i, err)
+				return
+			}
+		}
+		<-stream.Done()
+	})
+	base := serve(t, s)
+	defer s.Shutdown()
+
+	req, _ := http.NewRequest("GET", base+"/events", nil)
+	req.Header.Set("Last-Event-ID", "5")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Header.Get("Content-Type") != "text/event-stream" || rsp.Header.Get("Connection") != "" {
+		t.Errorf("unexpected headers %v", rsp.Header)
+	}
+	br := bufio.NewReader(rsp.Body)
+	events, comments := make([]string, 0), 0
+	for len(events) < 3 {
+		block := ""
+		for {
+			l, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatalf("stream broken after %d events: %v", len(events), err)
+			}
+			if l == "\n" {
+				break
+			}
+			block += l
+		}
+		if strings.HasPrefix(block, ": keepalive") {
+			comments++
+			continue
+		}
+		events = append(events, block)
+	}
+	if events[0] != "id: 6\nevent: tick\ndata: line1\ndata: line2\n" || !strings.HasPrefix(events[2], "id: 8\n") {
+		t.Errorf("unexpected events %q", events)
+	}
+	if comments == 0 {
+		t.Error("no keepalive comment")
+	}
+	rsp.Body.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("Done is not closed after client disconnects")
+	}
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+func newServer(t *testing.T) (*testserver.Server, string) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	os.MkdirAll(filepath.Join(root, "js"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "js", "app.js"), []byte("hello world"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "index.html"), []byte("<html>index</html>"), 0644)
+
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.StaticWithOption("/assets", root, &httpserver.StaticOption{MaxAge: 60})
+	s.SPA("/app", root, "index.html")
+	return testserver.New(t, s), dir
+}
+
+func TestStatic(t *testing.T) {
+	ts, dir := newServer(t)
+	defer os.RemoveAll(dir)
+
+	rsp := ts.GET("/assets/js/app.js").Expect(200).Body("hello world").
+		Header("Cache-Control", "public, max-age=60").Header("Accept-Ranges", "bytes").Then()
+	if ct := rsp.Header.Get("Content-Type"); ct != "text/javascript; charset=utf-8" && ct != "application/javascript" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	etag := rsp.Header.Get("ETag")
+	ts.GET("/assets/js/app.js").WithHeader("If-None-Match", etag).Expect(304).Body("")
+	ts.GET("/assets/js/app.js").WithHeader("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)).Expect(304)
+	ts.GET("/assets/js/app.js").WithHeader("Range", "bytes=6-").Expect(206).Body("world").Header("Content-Range", "bytes 6-10/11")
+	ts.HEAD("/assets/js/app.js").Expect(200).Body("").Header("Content-Length", "11")
+	ts.GET("/assets/").Expect(200).Body("<html>index</html>")
+	ts.POST("/assets/js/app.js").Expect(405)
+	ts.GET("/assets/js/none.js").Expect(404)
+}
+
+func TestStaticTraversal(t *testing.T) {
+	ts, dir := newServer(t)
+	defer os.RemoveAll(dir)
+
+	for _, p := range []string{"/assets/../secret.txt", "/assets/..%2fsecret.txt", "/assets/%2e%2e/secret.txt", "/assets/js/../../secret.txt", "/app/../secret.txt"} {
+		rsp := ts.GET(p).Do()
+		if rsp.Recorder.Body.String() == "secret" {
+			t.Errorf("%s escapes root", p)
+		}
+	}
+}
+
+func TestSPA(t *testing.T) {
+	ts, dir := newServer(t)
+	defer os.RemoveAll(dir)
+
+	ts.GET("/app/users/1").Expect(200).Body("<html>index</html>")
+	ts.GET("/app/js/app.js").Expect(200).Body("hello world")
+	ts.GET("/app/js/none.js").Expect(404)
+}
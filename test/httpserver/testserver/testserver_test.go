@@ -0,0 +1,52 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+	"github.com/FrankLeeC/Aurora/httpserver/testserver"
+)
+
+type token struct{}
+
+func (a *token) Before(rsp *httpserver.Response, req *httpserver.Request) bool {
+	if req.Header.Get("Token") != "secret" {
+		rsp.WriteHeader(401)
+		return false
+	}
+	return true
+}
+
+func (a *token) After(rsp *httpserver.Response, req *httpserver.Request) {
+	rsp.Header().Set("X-Checked", "1")
+}
+
+func TestHarness(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.DynamicFilter("/users/{id}", ts.Track("token", &token{}))
+	s.DynamicRoute("/users/{id}", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		rsp.Write([]byte(`{"id":"` + req.GetDynamicParam("id") + `","q":"` + req.URL.Query().Get("q") + `"}`))
+		return 200, nil
+	})
+
+	ts.GET("/users/1").Expect(401).Events("token.Before")
+	ts.GET("/users/1").WithHeader("Token", "secret").WithQuery("q", "x").Expect(200).
+		Header("X-Checked", "1").
+		JSONBody(map[string]string{"q": "x", "id": "1"}).
+		Events("token.Before", "token.After")
+	ts.GET("/other").Expect(404).NotRan("token")
+}
+
+func TestHeadersUntouched(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	ts := testserver.New(t, s)
+	s.Filter("/headers", ts.Track("token", &token{}))
+	s.Route("/headers", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		for k := range req.Header {
+			rsp.Write([]byte(k + ";"))
+		}
+		return 200, nil
+	})
+	ts.GET("/headers").WithHeader("Token", "secret").Expect(200).Body("Token;").Ran("token")
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+func post(t *testing.T, u string, content []byte) int {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	fw, _ := w.CreateFormFile("file", "../../a.png")
+	fw.Write(content)
+	w.Close()
+	rsp, err := http.Post(u, w.FormDataContentType(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	return rsp.StatusCode
+}
+
+func TestUpload(t *testing.T) {
+	var spooled string
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Upload("/upload", &httpserver.UploadOption{MaxSize: 4096, MaxMemory: 16, AllowedTypes: []string{"image/*"}})
+	s.Route("/upload", func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		f, err := req.File("file")
+		if err == httpserver.ErrUploadTooLarge {
+			return 413, err
+		} else if err == httpserver.ErrUploadType {
+			return 415, err
+		} else if err != nil {
+			return 400, err
+		}
+		if f.Filename != "a.png" || f.ContentType != "image/png" {
+			t.Errorf("unexpected file %s %s", f.Filename, f.ContentType)
+		}
+		r, _ := f.Open()
+		if o, ok := r.(*os.File); ok {
+			spooled = o.Name()
+		}
+		r.Close()
+		return 200, nil
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	u := "http://" + l.Addr().String() + "/upload"
+
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), make([]byte, 100)...)
+	if code := post(t, u, png); code != 200 {
+		t.Fatalf("expect 200, got %d", code)
+	}
+	if spooled == "" {
+		t.Error("file larger than MaxMemory should be spooled to a temp file")
+	} else if _, err := os.Stat(spooled); !os.IsNotExist(err) {
+		t.Errorf("temp file %s is not removed", spooled)
+	}
+	if code := post(t, u, []byte("plain text")); code != 415 {
+		t.Errorf("expect 415, got %d", code)
+	}
+	big := append(png, make([]byte, 8192)...)
+	if code := post(t, u, big); code != 413 {
+		t.Errorf("expect 413, got %d", code)
+	}
+	// chunked body has no length to check up front, limit is hit while parsing
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	fw, _ := w.CreateFormFile("file", "a.png")
+	fw.Write(big)
+	w.Close()
+	r, _ := http.NewRequest("POST", u, io.MultiReader(body))
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if r.ContentLength != 0 || rsp.StatusCode != 413 {
+		t.Errorf("chunked: expect 413, got %d", rsp.StatusCode)
+	}
+}
+
+func TestFilesAfterFormValue(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.Upload("/limited", &httpserver.UploadOption{MaxSize: 1024, AllowedTypes: []string{"image/*"}})
+	handler := func(rsp *httpserver.Response, req *httpserver.Request) (uint, error) {
+		req.FormValue("name") // form is parsed without limits of route
+		f, err := req.File("file")
+		if err == httpserver.ErrUploadTooLarge {
+			rsp.WriteStatusCode(413)
+			return 413, nil
+		} else if err == httpserver.ErrUploadType {
+			rsp.WriteStatusCode(415)
+			return 415, nil
+		} else if err != nil || f == nil {
+			return 400, err
+		}
+		return 200, nil
+	}
+	s.Route("/default", handler)
+	s.Route("/limited", handler)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	defer s.Shutdown()
+	base := "http://" + l.Addr().String()
+
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), make([]byte, 100)...)
+	if code := post(t, base+"/default", png); code != 200 {
+		t.Errorf("route without upload options: expect 200, got %d", code)
+	}
+	if code := post(t, base+"/limited", png); code != 200 {
+		t.Errorf("small file: expect 200, got %d", code)
+	}
+	if code := post(t, base+"/limited", []byte("plain text")); code != 415 {
+		t.Errorf("text file: expect 415, got %d", code)
+	}
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	fw, _ := w.CreateFormFile("file", "a.png")
+	fw.Write(append(png, make([]byte, 2048)...))
+	w.Close()
+	req, _ := http.NewRequest("POST", base+"/limited", struct{ io.Reader }{body}) // no content length
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != 413 {
+		t.Errorf("large file: expect 413, got %d", rsp.StatusCode)
+	}
+}
@@ -0,0 +1,210 @@
+package test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/httpserver"
+)
+
+func serve(t *testing.T, s *httpserver.HTTPServer) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Finish(func(e error) {})
+	s.ServeListener(l)
+	return l.Addr().String()
+}
+
+func echo(conn *httpserver.WSConn, req *httpserver.Request) {
+	go conn.KeepAlive(time.Minute, time.Minute) // KeepAlive may run concurrently with ReadMessage
+	for {
+		mt, b, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(mt, b)
+	}
+}
+
+type client struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dial do handshake, returns status code of handshake
+func dial(t *testing.T, addr, origin string) (*client, int) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req := "GET /ws HTTP/1.1\r\nHost: " + addr + "\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"
+	if origin != "" {
+		req += "Origin: " + origin + "\r\n"
+	}
+	conn.Write([]byte(req + "\r\n"))
+	br := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode == http.StatusSwitchingProtocols && rsp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected Sec-WebSocket-Accept %q", rsp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	return &client{conn: conn, br: br}, rsp.StatusCode
+}
+
+func (a *client) write(fin bool, opcode int, payload []byte, masked bool) {
+	b := []byte{byte(opcode), 0}
+	if fin {
+		b[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		b[1] = byte(n)
+	case n <= 0xffff:
+		b[1] = 126
+		b = append(b, byte(n>>8), byte(n))
+	default:
+		b[1] = 127
+		l := make([]byte, 8)
+		binary.BigEndian.PutUint64(l, uint64(n))
+		b = append(b, l...)
+	}
+	p := append([]byte(nil), payload...)
+	if masked {
+		b[1] |= 0x80
+		mask := []byte{1, 2, 3, 4}
+		b = append(b, mask...)
+		for i := range p {
+			p[i] ^= mask[i%4]
+		}
+	}
+	a.conn.Write(append(b, p...))
+}
+
+func (a *client) read(t *testing.T) (int, []byte) {
+	h := make([]byte, 2)
+	if _, err := io.ReadFull(a.br, h); err != nil {
+		t.Fatal(err)
+	}
+	if h[1]&0x80 != 0 {
+		t.Error("frames from server must not be masked")
+	}
+	op, n := int(h[0]&0x0f), int(h[1]&0x7f)
+	if n == 126 {
+		io.ReadFull(a.br, h)
+		n = int(binary.BigEndian.Uint16(h))
+	}
+	p := make([]byte, n)
+	io.ReadFull(a.br, p)
+	return op, p
+}
+
+func (a *client) expectClose(t *testing.T, code int) {
+	t.Helper()
+	op, p := a.read(t)
+	if op != httpserver.CloseMessage || len(p) < 2 || int(binary.BigEndian.Uint16(p)) != code {
+		t.Errorf("expect close %d, got opcode %d payload %v", code, op, p)
+	}
+}
+
+func TestFraming(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.WebSocket("/ws", echo)
+	addr := serve(t, s)
+	defer s.Shutdown()
+
+	c, code := dial(t, addr, "")
+	if code != 101 {
+		t.Fatalf("expect 101, got %d", code)
+	}
+	defer c.conn.Close()
+	c.write(true, httpserver.TextMessage, []byte("hello"), true)
+	if op, p := c.read(t); op != httpserver.TextMessage || string(p) != "hello" {
+		t.Errorf("unexpected echo %d %q", op, p)
+	}
+	big := []byte(strings.Repeat("a", 1000))
+	c.write(true, httpserver.BinaryMessage, big, true)
+	if op, p := c.read(t); op != httpserver.BinaryMessage || string(p) != string(big) {
+		t.Errorf("unexpected echo of 1000 bytes %d %d", op, len(p))
+	}
+	// fragmented message with a ping in between
+	c.write(false, httpserver.TextMessage, []byte("hel"), true)
+	c.write(true, httpserver.PingMessage, []byte("p"), true)
+	c.write(true, 0, []byte("lo"), true)
+	if op, p := c.read(t); op != httpserver.PongMessage || string(p) != "p" {
+		t.Errorf("expect pong, got %d %q", op, p)
+	}
+	if op, p := c.read(t); op != httpserver.TextMessage || string(p) != "hello" {
+		t.Errorf("unexpected reassembled message %d %q", op, p)
+	}
+	c.write(true, httpserver.CloseMessage, []byte{0x03, 0xe8}, true)
+	c.expectClose(t, httpserver.CloseNormalClosure)
+	if _, err := c.br.ReadByte(); err != io.EOF {
+		t.Errorf("connection should be closed, got %v", err)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.WebSocket("/ws", echo)
+	addr := serve(t, s)
+	defer s.Shutdown()
+
+	c, _ := dial(t, addr, "")
+	c.write(true, httpserver.TextMessage, []byte("hello"), false)
+	c.expectClose(t, httpserver.CloseProtocolError)
+	c.conn.Close()
+
+	c, _ = dial(t, addr, "")
+	c.write(true, 0, []byte("x"), true)
+	c.expectClose(t, httpserver.CloseProtocolError)
+	c.conn.Close()
+
+	c, _ = dial(t, addr, "")
+	c.write(true, httpserver.TextMessage, []byte{0xff, 0xfe}, true)
+	c.expectClose(t, httpserver.CloseInvalidFramePayloadData)
+	c.conn.Close()
+}
+
+func TestCheckOrigin(t *testing.T) {
+	s := httpserver.NewHTTPServerWithOption(nil)
+	s.WebSocket("/ws", echo)
+	addr := serve(t, s)
+	defer s.Shutdown()
+
+	if c, code := dial(t, addr, "http://evil.com"); code != http.StatusForbidden {
+		t.Errorf("cross-origin handshake: expect 403, got %d", code)
+		c.conn.Close()
+	}
+	c, code := dial(t, addr, "http://"+addr)
+	if code != 101 {
+		t.Errorf("same-origin handshake: expect 101, got %d", code)
+	}
+	c.conn.Close()
+
+	s2 := httpserver.NewHTTPServerWithOption(nil)
+	s2.WebSocketWithOption("/ws", &httpserver.WSOption{CheckOrigin: func(req *httpserver.Request) bool {
+		return req.Header.Get("Origin") == "https://app.example.com"
+	}}, echo)
+	addr2 := serve(t, s2)
+	defer s2.Shutdown()
+	c, code = dial(t, addr2, "https://app.example.com")
+	if code != 101 {
+		t.Errorf("allowed origin: expect 101, got %d", code)
+	}
+	c.conn.Close()
+	if _, code = dial(t, addr2, "http://"+addr2); code != http.StatusForbidden {
+		t.Errorf("custom CheckOrigin: expect 403, got %d", code)
+	}
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/log"
+)
+
+func newLogger(t *testing.T, encoder log.Encoder) (*log.Logger, string) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.log")
+	l := log.NewLogger(path, &log.LoggerOption{Encoder: encoder})
+	return l, path
+}
+
+func lines(t *testing.T, l *log.Logger, path string) []string {
+	l.Close()
+	defer os.RemoveAll(filepath.Dir(path))
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestTextEncoder(t *testing.T) {
+	l, path := newLogger(t, nil)
+	l.Info("count %d", 3)
+	l.With("user", "frank").Warn("login", "ip", "127.0.0.1", "note", "two words")
+	ls := lines(t, l, path)
+	if strings.Contains(ls[0], "\x1b[") {
+		t.Errorf("log file should not be colored: %q", ls[0])
+	}
+	if !strings.HasPrefix(ls[0], "[INFO] ") || !strings.Contains(ls[0], " log_test.go:") || !strings.HasSuffix(ls[0], ":count 3") {
+		t.Errorf("unexpected line %q", ls[0])
+	}
+	if !strings.HasSuffix(ls[1], `:login user=frank ip=127.0.0.1 note="two words"`) {
+		t.Errorf("unexpected line %q", ls[1])
+	}
+}
+
+func TestLiteralPercent(t *testing.T) {
+	l, path := newLogger(t, nil)
+	l.Info("cpu at 95%", "host", "a")
+	l.Info("disk at 100%% on %s", "sda")
+	ls := lines(t, l, path)
+	if !strings.HasSuffix(ls[0], ":cpu at 95% host=a") {
+		t.Errorf("unexpected line %q", ls[0])
+	}
+	if !strings.HasSuffix(ls[1], ":disk at 100% on sda") {
+		t.Errorf("unexpected line %q", ls[1])
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	l, path := newLogger(t, &log.JSONEncoder{})
+	l.With("user", 1).Error("failed", "err", errors.New("boom"), "odd")
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines(t, l, path)[0]), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["level"] != "error" || m["msg"] != "failed" || m["user"] != 1.0 || m["err"] != "boom" || m["!BADKEY"] != "odd" {
+		t.Errorf("unexpected entry %v", m)
+	}
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	l, path := newLogger(t, &log.LogfmtEncoder{})
+	l.Trace("a b", "k", "v=1")
+	line := lines(t, l, path)[0]
+	if !strings.Contains(line, ` level=trace caller=log_test.go:`) || !strings.HasSuffix(line, ` msg="a b" k="v=1"`) {
+		t.Errorf("unexpected line %q", line)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	all, warn := &bytes.Buffer{}, &bytes.Buffer{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+		conn.Close()
+	}()
+	tcp, err := log.NewNetworkSink("tcp", l.Addr().String(), &log.SinkOption{Level: log.Error, Encoder: &log.JSONEncoder{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.New(log.NewMultiSink(
+		log.NewWriterSink(all, nil),
+		log.NewWriterSink(warn, &log.SinkOption{Level: log.Warn}),
+		tcp,
+	), &log.LoggerOption{Mode: log.Time})
+	logger.Info("info")
+	logger.Error("error", "code", 500)
+	logger.Close()
+
+	if n := strings.Count(all.String(), "\n"); n != 2 {
+		t.Errorf("expect 2 lines, got %q", all.String())
+	}
+	if strings.Contains(warn.String(), "info") || !strings.Contains(warn.String(), "[ERROR]") {
+		t.Errorf("warn sink should only get error: %q", warn.String())
+	}
+	select {
+	case line := <-received:
+		if !strings.Contains(line, `"msg":"error","code":500}`) {
+			t.Errorf("unexpected line %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Error("tcp sink did not ship logs")
+	}
+	if err := log.NewWriterSink(all, nil).Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+type slowSink struct {
+	mutex sync.Mutex
+	lines []string
+	gate  chan struct{}
+}
+
+func (a *slowSink) Write(e *log.Entry) error {
+	<-a.gate
+	a.mutex.Lock()
+	a.lines = append(a.lines, e.Message)
+	a.mutex.Unlock()
+	return nil
+}
+
+func (a *slowSink) Close() error {
+	return nil
+}
+
+func TestAsyncSink(t *testing.T) {
+	sink := &slowSink{gate: make(chan struct{})}
+	async := log.NewAsyncSink(sink, &log.AsyncOption{QueueSize: 2, BatchSize: 1, Overflow: log.OverflowDropOldest})
+	logger := log.New(async, nil)
+	logger.Info("0")
+	time.Sleep(20 * time.Millisecond) // writer takes 0 and waits at gate
+	for i := 1; i <= 4; i++ {
+		logger.Info(strconv.Itoa(i))
+	}
+	if async.Dropped() != 2 {
+		t.Errorf("expect 2 dropped, got %d", async.Dropped())
+	}
+	close(sink.gate)
+	logger.Flush()
+	if strings.Join(sink.lines, ",") != "0,3,4" {
+		t.Errorf("unexpected lines %v", sink.lines)
+	}
+	logger.Close()
+	if err := async.Write(&log.Entry{}); err != log.ErrSinkClosed {
+		t.Errorf("write after close: %v", err)
+	}
+
+	l, path := newLogger(t, nil)
+	l.Close()
+	os.Remove(path)
+	file, err := log.NewFileSink(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger = log.New(file, &log.LoggerOption{Async: &log.AsyncOption{}})
+	for i := 0; i < 1000; i++ {
+		logger.Info("line", "i", i)
+	}
+	if n := len(lines(t, logger, path)); n != 1000 {
+		t.Errorf("expect 1000 lines after close, got %d", n)
+	}
+}
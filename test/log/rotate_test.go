@@ -0,0 +1,382 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FrankLeeC/Aurora/log"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (a *fakeClock) Now() time.Time {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.now
+}
+
+func (a *fakeClock) Add(d time.Duration) {
+	a.mutex.Lock()
+	a.now = a.now.Add(d)
+	a.mutex.Unlock()
+}
+
+type memData struct {
+	buf     bytes.Buffer
+	modTime time.Time
+}
+
+type memInfo struct {
+	name string
+	data *memData
+}
+
+func (a memInfo) Name() string       { return a.name }
+func (a memInfo) Size() int64        { return int64(a.data.buf.Len()) }
+func (a memInfo) Mode() os.FileMode  { return 0644 }
+func (a memInfo) ModTime() time.Time { return a.data.modTime }
+func (a memInfo) IsDir() bool        { return false }
+func (a memInfo) Sys() interface{}   { return nil }
+
+// memFS in memory file system, directories always exist
+type memFS struct {
+	mutex sync.Mutex
+	clock *fakeClock
+	files map[string]*memData
+	fail  bool // OpenFile fails
+	stuck bool // Rename fails
+}
+
+func newMemFS(clock *fakeClock) *memFS {
+	return &memFS{clock: clock, files: make(map[string]*memData)}
+}
+
+type memFile struct {
+	fs   *memFS
+	data *memData
+	r    *bytes.Reader
+}
+
+func (a *memFile) Read(p []byte) (int, error) {
+	return a.r.Read(p)
+}
+
+func (a *memFile) Write(p []byte) (int, error) {
+	a.fs.mutex.Lock()
+	defer a.fs.mutex.Unlock()
+	a.data.modTime = a.fs.clock.Now()
+	return a.data.buf.Write(p)
+}
+
+func (a *memFile) Close() error {
+	return nil
+}
+
+func (a *memFS) OpenFile(name string, flag int, perm os.FileMode) (log.File, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.fail {
+		return nil, errors.New("open failed")
+	}
+	d, ok := a.files[name]
+	if !ok {
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		d = &memData{modTime: a.clock.Now()}
+		a.files[name] = d
+	}
+	if flag&os.O_TRUNC != 0 {
+		d.buf.Reset()
+	}
+	return &memFile{fs: a, data: d, r: bytes.NewReader(append([]byte(nil), d.buf.Bytes()...))}, nil
+}
+
+func (a *memFS) Stat(name string) (os.FileInfo, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	d, ok := a.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return memInfo{name: filepath.Base(name), data: d}, nil
+}
+
+func (a *memFS) Rename(oldpath, newpath string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	d, ok := a.files[oldpath]
+	if a.stuck {
+		return &os.PathError{Op: "rename", Path: oldpath, Err: os.ErrPermission}
+	}
+	if !ok {
+		return &os.PathError{Op: "rename", Path: oldpath, Err: os.ErrNotExist}
+	}
+	delete(a.files, oldpath)
+	a.files[newpath] = d
+	return nil
+}
+
+func (a *memFS) Remove(name string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.files, name)
+	return nil
+}
+
+func (a *memFS) MkdirAll(path string, perm os.FileMode) error {
+	return nil
+}
+
+func (a *memFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	fl := make([]os.FileInfo, 0)
+	for name, d := range a.files {
+		if filepath.Dir(name) == dirname {
+			fl = append(fl, memInfo{name: filepath.Base(name), data: d})
+		}
+	}
+	return fl, nil
+}
+
+// contents file name -> content
+func (a *memFS) contents() map[string]string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	m := make(map[string]string)
+	for name, d := range a.files {
+		m[filepath.Base(name)] = d.buf.String()
+	}
+	return m
+}
+
+// messageEncoder encode message only
+type messageEncoder struct{}
+
+func (messageEncoder) Encode(e *log.Entry) []byte {
+	return []byte(e.Message)
+}
+
+var logDir = filepath.Join(os.TempDir(), "rotate")
+
+func newRotateSink(t *testing.T, option *log.LoggerOption) (*log.FileSink, *memFS, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2018, 3, 4, 10, 0, 0, 0, time.Local)}
+	fs := newMemFS(clock)
+	if option == nil {
+		option = &log.LoggerOption{}
+	}
+	option.Clock, option.FS, option.Encoder = clock, fs, messageEncoder{}
+	sink, err := log.NewFileSink(filepath.Join(logDir, "app.log"), option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink, fs, clock
+}
+
+func write(sink *log.FileSink, messages ...string) {
+	for _, m := range messages {
+		sink.Write(&log.Entry{Level: log.Info, Message: m})
+	}
+}
+
+func expectFiles(t *testing.T, fs *memFS, expect map[string]string) {
+	t.Helper()
+	got := fs.contents()
+	if len(got) != len(expect) {
+		t.Errorf("expect files %v, got %v", expect, got)
+		return
+	}
+	for name, content := range expect {
+		if got[name] != content {
+			t.Errorf("file %s: expect %q, got %q", name, content, got[name])
+		}
+	}
+}
+
+func TestSizeRotation(t *testing.T) {
+	sink, fs, _ := newRotateSink(t, &log.LoggerOption{MaxSize: 10})
+	write(sink, "aaaa", "bbbb", "cccc", "dddddddddddddd", "e")
+	sink.Close()
+	expectFiles(t, fs, map[string]string{
+		"app_20180304_1.log": "aaaa\nbbbb\n",
+		"app_20180304_2.log": "cccc\n",
+		"app_20180304_3.log": "dddddddddddddd\n",
+		"app.log":            "e\n",
+	})
+}
+
+func TestRenameError(t *testing.T) {
+	sink, fs, clock := newRotateSink(t, &log.LoggerOption{MaxSize: 10})
+	fs.stuck = true
+	write(sink, "aaaa", "bbbb", "cccc")
+	fs.stuck = false
+	write(sink, "d")
+	clock.Add(2 * time.Second)
+	write(sink, "e")
+	sink.Close()
+	expectFiles(t, fs, map[string]string{
+		"app_20180304_1.log": "aaaa\nbbbb\ncccc\nd\n",
+		"app.log":            "e\n",
+	})
+}
+
+func TestLineRotation(t *testing.T) {
+	sink, fs, _ := newRotateSink(t, &log.LoggerOption{MaxLine: 2})
+	write(sink, "1", "2", "3", "4", "5")
+	sink.Close()
+	expectFiles(t, fs, map[string]string{
+		"app_20180304_1.log": "1\n2\n",
+		"app_20180304_2.log": "3\n4\n",
+		"app.log":            "5\n",
+	})
+}
+
+func TestDailyRotation(t *testing.T) {
+	sink, fs, clock := newRotateSink(t, nil)
+	clock.Add(13*time.Hour + 59*time.Minute) // 23:59
+	write(sink, "1", "2")
+	clock.Add(2 * time.Minute)
+	write(sink, "3")
+	clock.Add(24 * time.Hour)
+	write(sink, "4")
+	sink.Close()
+	expectFiles(t, fs, map[string]string{
+		"app_20180304_1.log": "1\n2\n",
+		"app_20180305_1.log": "3\n",
+		"app.log":            "4\n",
+	})
+}
+
+func TestHourlyRotation(t *testing.T) {
+	sink, fs, clock := newRotateSink(t, &log.LoggerOption{Rotate: log.AnyPolicy(log.HourlyPolicy(), log.LinePolicy(2))})
+	write(sink, "1", "2", "3")
+	clock.Add(30 * time.Minute)
+	write(sink, "4")
+	clock.Add(30 * time.Minute)
+	write(sink, "5")
+	sink.Close()
+	expectFiles(t, fs, map[string]string{
+		"app_20180304_1.log": "1\n2\n",
+		"app_20180304_2.log": "3\n4\n",
+		"app.log":            "5\n",
+	})
+}
+
+func TestRotateExistingFile(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2018, 3, 3, 8, 0, 0, 0, time.Local)}
+	fs := newMemFS(clock)
+	path := filepath.Join(logDir, "app.log")
+	f, _ := fs.OpenFile(path, os.O_CREATE, 0644)
+	f.Write([]byte("old\n"))
+	clock.Add(24 * time.Hour)
+
+	sink, err := log.NewFileSink(path, &log.LoggerOption{Clock: clock, FS: fs, Encoder: messageEncoder{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(sink, "new")
+	sink.Close()
+	expectFiles(t, fs, map[string]string{
+		"app_20180303_1.log": "old\n",
+		"app.log":            "new\n",
+	})
+}
+
+func TestRemovedFileIsCreatedAgain(t *testing.T) {
+	sink, fs, clock := newRotateSink(t, &log.LoggerOption{MaxLine: 10})
+	write(sink, "1")
+	fs.Remove(filepath.Join(logDir, "app.log"))
+	clock.Add(2 * time.Second)
+	write(sink, "2")
+	sink.Close()
+	expectFiles(t, fs, map[string]string{"app.log": "2\n"})
+	if err := sink.Write(&log.Entry{Message: "3"}); err != log.ErrSinkClosed {
+		t.Errorf("write after close: %v", err)
+	}
+}
+
+func TestOpenError(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	fs := newMemFS(clock)
+	fs.fail = true
+	if _, err := log.NewFileSink(filepath.Join(logDir, "app.log"), &log.LoggerOption{Clock: clock, FS: fs}); err == nil {
+		t.Error("expect open error")
+	}
+}
+
+func TestConcurrentRotation(t *testing.T) {
+	sink, fs, clock := newRotateSink(t, &log.LoggerOption{MaxLine: 100, MaxSize: 1000})
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				write(sink, strconv.Itoa(g)+"-"+strconv.Itoa(i))
+				if i%50 == 0 {
+					clock.Add(time.Hour)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	sink.Close()
+	all := make([]string, 0)
+	for name, content := range fs.contents() {
+		ls := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		if len(ls) > 100 || len(content) > 1000 {
+			t.Errorf("file %s has %d lines and %d bytes", name, len(ls), len(content))
+		}
+		all = append(all, ls...)
+	}
+	sort.Strings(all)
+	if len(all) != 1600 {
+		t.Errorf("expect 1600 lines, got %d", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i] == all[i-1] {
+			t.Errorf("duplicated line %s", all[i])
+		}
+	}
+}
+
+func TestFilePermission(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a", "b", "app.log")
+	sink, err := log.NewFileSink(path, &log.LoggerOption{MaxLine: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(sink, "1", "2")
+	sink.Close()
+	for _, p := range []string{filepath.Dir(path), path} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm()&^0755 != 0 || fi.Mode().Perm()&0600 != 0600 {
+			t.Errorf("unexpected mode %s of %s", fi.Mode(), p)
+		}
+	}
+	fl, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(fl) != 2 {
+		t.Errorf("expect 2 files, got %d", len(fl))
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/FrankLeeC/Aurora/metrics"
+)
+
+func TestWriteText(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.Counter("requests_total", "Count of requests.", "route", "code")
+	c.Inc("/a/{id}", "200")
+	c.Add(2, "/a/{id}", "200")
+	c.Inc("/b", "404")
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/a/{id}")
+	h.Observe(0.5, "/a/{id}")
+	r.GaugeFunc("queue_waiting", "Waiting jobs.", func() float64 { return 7 })
+
+	b := new(bytes.Buffer)
+	if err := r.WriteText(b); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`# TYPE latency_seconds histogram`,
+		`latency_seconds_bucket{route="/a/{id}",le="0.1"} 1`,
+		`latency_seconds_bucket{route="/a/{id}",le="1"} 2`,
+		`latency_seconds_bucket{route="/a/{id}",le="+Inf"} 2`,
+		`latency_seconds_sum{route="/a/{id}"} 0.55`,
+		`latency_seconds_count{route="/a/{id}"} 2`,
+		`queue_waiting 7`,
+		`# TYPE requests_total counter`,
+		`requests_total{route="/a/{id}",code="200"} 3`,
+		`requests_total{route="/b",code="404"} 1`,
+	}
+	for _, s := range expected {
+		if !strings.Contains(b.String(), s+"\n") {
+			t.Errorf("missing %q in:\n%s", s, b.String())
+		}
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := metrics.NewRegistry()
+	a := r.Counter("x_total", "x")
+	if b := r.Counter("x_total", "x"); a != b {
+		t.Error("same counter expected")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("panic expected when kind differs")
+		}
+	}()
+	r.Gauge("x_total", "x")
+}